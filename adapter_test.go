@@ -1,7 +1,11 @@
 package crud
 
 import (
+	"bytes"
+	"encoding/json"
+	"github.com/jakecoffman/crud/option"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -103,4 +107,161 @@ func TestNewServeMuxAdapter(t *testing.T) {
 			t.Errorf("unexpected body %q", w.Body.String())
 		}
 	})
+
+	t.Run("validates headers", func(t *testing.T) {
+		adapter := NewServeMuxAdapter()
+		router := NewRouter("title", "1.0", adapter)
+		err := router.Add(Spec{
+			Method: "GET",
+			Path:   "/widgets",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, r.Header.Get("X-Limit"))
+			},
+			Validate: Validate{
+				Header: Object(map[string]Field{
+					"X-Token": String().Required(),
+					"X-Limit": Integer().Max(10).Default(5),
+				}),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest("GET", "/widgets", nil)
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		r = httptest.NewRequest("GET", "/widgets", nil)
+		r.Header.Set("X-Token", "secret")
+		w = httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		// default should be applied
+		if w.Body.String() != "5" {
+			t.Errorf("unexpected body %q", w.Body.String())
+		}
+	})
+
+	t.Run("validates multipart forms", func(t *testing.T) {
+		adapter := NewServeMuxAdapter()
+		router := NewRouter("title", "1.0", adapter)
+		err := router.Add(Spec{
+			Method: "POST",
+			Path:   "/widgets",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, r.FormValue("name")+r.FormValue("unexpected"))
+			},
+			Validate: Validate{
+				FormData: Object(map[string]Field{
+					"name":  String().Required(),
+					"image": File().Required().MimeTypes("image/png"),
+				}),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		newRequest := func(image string) *http.Request {
+			var buf bytes.Buffer
+			mw := multipart.NewWriter(&buf)
+			_ = mw.WriteField("name", "bob")
+			_ = mw.WriteField("unexpected", "value")
+			fw, _ := mw.CreateFormFile("image", "image.png")
+			_, _ = io.WriteString(fw, image)
+			_ = mw.Close()
+			r := httptest.NewRequest("POST", "/widgets", &buf)
+			r.Header.Set("Content-Type", mw.FormDataContentType())
+			return r
+		}
+
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, newRequest("not an image"))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		w = httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, newRequest("\x89PNG\r\n\x1a\n"))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d: %v", http.StatusOK, w.Code, w.Body.String())
+		}
+		// unexpected value should be stripped
+		if w.Body.String() != "bob" {
+			t.Errorf("unexpected body %q", w.Body.String())
+		}
+	})
+
+	t.Run("collects errors when the spec asks for it", func(t *testing.T) {
+		adapter := NewServeMuxAdapter()
+		router := NewRouter("title", "1.0", adapter)
+		err := router.Add(Spec{
+			Method:  "POST",
+			Path:    "/widgets",
+			Handler: func(w http.ResponseWriter, r *http.Request) {},
+			Validate: Validate{
+				Body: Object(map[string]Field{
+					"name":     String().Required(),
+					"quantity": Integer().Min(1),
+				}),
+			},
+			Options: []option.Option{option.CollectErrors(10)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest("POST", "/widgets", strings.NewReader(`{"quantity":0}`))
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		var problem Problem
+		if err = json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		errs := problem.Errors
+		if len(errs) != 2 || errs[0].Pointer != "/name" || errs[1].Pointer != "/quantity" {
+			t.Errorf("unexpected body %v", w.Body.String())
+		}
+	})
+}
+
+func BenchmarkServeMuxAdapter(b *testing.B) {
+	field, data := benchmarkBody()
+	adapter := NewServeMuxAdapter()
+	router := NewRouter("title", "1.0", adapter)
+	err := router.Add(Spec{
+		Method: "POST",
+		Path:   "/orders",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			_ = InputsFromContext(r.Context()).Body
+		},
+		Validate: Validate{Body: field},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, httptest.NewRequest("POST", "/orders", bytes.NewReader(data)))
+		if w.Code != http.StatusOK {
+			b.Fatal(w.Code, w.Body.String())
+		}
+	}
 }
@@ -1,13 +1,12 @@
 package adapter
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"github.com/jakecoffman/crud"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
-	"io/ioutil"
+	"mime/multipart"
+	"net/http"
 	"net/url"
 	"reflect"
 )
@@ -50,8 +49,20 @@ func (a *Adapter) Install(r *crud.Router, spec *crud.Spec) error {
 		handler = v
 	case func(echo.Context) error:
 		handler = v
+	case http.Handler:
+		handler = echo.WrapHandler(v)
 	default:
-		return fmt.Errorf("handler must be echo.HandlerFunc or func(echo.Context) error, got %v", reflect.TypeOf(spec.Handler))
+		return fmt.Errorf("handler must be echo.HandlerFunc, func(echo.Context) error or http.Handler, got %v", reflect.TypeOf(spec.Handler))
+	}
+	if validate := r.ResponseValidator(spec); validate != nil {
+		next := handler
+		handler = echo.WrapMiddleware(validate)(func(c echo.Context) error {
+			// handle errors here so the error response is buffered and checked too
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			return nil
+		})
 	}
 
 	a.Echo.Add(spec.Method, swaggerToEchoPattern(spec.Path), handler, middlewares...)
@@ -82,6 +93,11 @@ func wrap(r *crud.Router, spec *crud.Spec) echo.MiddlewareFunc {
 			var query url.Values
 			var body interface{}
 			var path map[string]string
+			var decoded *crud.Body
+			// the handler is done with the body once this returns
+			defer func() {
+				decoded.Release()
+			}()
 
 			// need this scope so the defers run before next is called
 			err := func() error {
@@ -93,18 +109,13 @@ func wrap(r *crud.Router, spec *crud.Spec) echo.MiddlewareFunc {
 				}
 
 				if val.Body.Initialized() && val.Body.Kind() != crud.KindFile {
-					if err := json.NewDecoder(c.Request().Body).Decode(&body); err != nil {
-						_ = c.JSON(400, err.Error())
-						return fmt.Errorf("failed to bind body %w", err)
+					var err error
+					if decoded, err = r.DecodeBody(c.Request(), spec); err != nil {
+						err = fmt.Errorf("failure decoding body: %w", err)
+						r.RenderError(c.Response(), c.Request(), spec, err)
+						return err
 					}
-
-					defer func() {
-						data, err := json.Marshal(body)
-						if err != nil {
-							panic(err)
-						}
-						c.Request().Body = ioutil.NopCloser(bytes.NewReader(data))
-					}()
+					body = decoded.Value
 				}
 
 				if val.Query.Initialized() {
@@ -114,10 +125,31 @@ func wrap(r *crud.Router, spec *crud.Spec) echo.MiddlewareFunc {
 					}()
 				}
 
-				if err := r.Validate(val, query, body, path); err != nil {
-					_ = c.JSON(400, err.Error())
+				var form url.Values
+				var files map[string][]*multipart.FileHeader
+				if val.FormData.Initialized() {
+					var err error
+					if form, files, err = crud.ParseForm(c.Request()); err != nil {
+						err = fmt.Errorf("failure decoding form: %w", err)
+						r.RenderError(c.Response(), c.Request(), spec, err)
+						return err
+					}
+					defer func() {
+						// Request().Form is rebuilt from the validated PostForm and query on next use
+						c.Request().Form = nil
+					}()
+				}
+
+				inputs := &crud.Inputs{Path: path, Query: query, Header: c.Request().Header, Body: body, Form: form, Files: files}
+				if err := r.ValidateInputs(val, inputs, spec.Options...); err != nil {
+					r.RenderError(c.Response(), c.Request(), spec, err)
 					return err
 				}
+				if decoded != nil {
+					// only encoded again if validation changed it
+					c.Request().Body = decoded.Reader(inputs.BodyChanged)
+				}
+				c.SetRequest(c.Request().WithContext(crud.ContextWithInputs(c.Request().Context(), inputs)))
 
 				return nil
 			}()
@@ -1,9 +1,16 @@
 package adapter
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"github.com/jakecoffman/crud"
 	"github.com/jakecoffman/crud/adapters/echo-adapter/example/widgets"
+	"github.com/jakecoffman/crud/option"
+	"github.com/labstack/echo/v4"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -41,4 +48,123 @@ func TestExampleServer(t *testing.T) {
 			t.Error(w.Result().StatusCode)
 		}
 	})
+
+	t.Run("POST /widgets", func(t *testing.T) {
+		// missing the required Authentication header
+		r := httptest.NewRequest("POST", "/widgets", strings.NewReader(`{"name":"Bob"}`))
+		r.Header.Set("content-type", "application/json")
+		w := httptest.NewRecorder()
+		adapter.Echo.ServeHTTP(w, r)
+
+		if w.Result().StatusCode != 400 {
+			t.Error(w.Result().StatusCode)
+		}
+
+		r = httptest.NewRequest("POST", "/widgets", strings.NewReader(`{"name":"Bob"}`))
+		r.Header.Set("content-type", "application/json")
+		r.Header.Set("Authentication", "password")
+		w = httptest.NewRecorder()
+		adapter.Echo.ServeHTTP(w, r)
+
+		if w.Result().StatusCode != 200 {
+			t.Error(w.Result().StatusCode, w.Body.String())
+		}
+	})
+}
+
+func TestResponseValidation(t *testing.T) {
+	adapter := New()
+	var reported []error
+	router := crud.NewRouter("Widget API", "1.0.0", adapter, option.ValidateResponses(1), option.OnResponseError(func(r *http.Request, err error) {
+		reported = append(reported, err)
+	}))
+
+	err := router.Add(crud.Spec{
+		Method: "GET",
+		Path:   "/widgets/{id}",
+		Handler: func(c echo.Context) error {
+			if c.Param("id") == "1" {
+				return c.JSON(200, map[string]interface{}{"id": 1})
+			}
+			return echo.NewHTTPError(404, "not found")
+		},
+		Validate: crud.Validate{
+			Path: crud.Object(map[string]crud.Field{
+				"id": crud.Integer().Required(),
+			}),
+		},
+		Responses: map[string]crud.Response{
+			"200": {
+				Schema: crud.JsonSchema{
+					Type:       crud.KindObject,
+					Properties: map[string]crud.JsonSchema{"id": {Type: crud.KindInteger}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	adapter.Echo.ServeHTTP(w, httptest.NewRequest("GET", "/widgets/1", nil))
+	if w.Code != 200 || len(reported) != 0 {
+		t.Errorf("unexpected response %v %v %v", w.Code, w.Body.String(), reported)
+	}
+
+	// the error returned by the handler is rendered and checked, 404 isn't documented
+	w = httptest.NewRecorder()
+	adapter.Echo.ServeHTTP(w, httptest.NewRequest("GET", "/widgets/2", nil))
+	if w.Code != 404 || len(reported) != 1 || !errors.Is(reported[0], crud.ErrUndocumented) {
+		t.Errorf("unexpected response %v %v %v", w.Code, w.Body.String(), reported)
+	}
+}
+
+// benchmarkBody is a body with a few hundred values, like the larger ones seen in practice.
+func benchmarkBody() (crud.Field, []byte) {
+	field := crud.Object(map[string]crud.Field{
+		"name": crud.String().Required(),
+		"items": crud.Array().Items(crud.Object(map[string]crud.Field{
+			"id":    crud.Integer().Required().Min(1),
+			"sku":   crud.String().Pattern("^[A-Z0-9-]+$"),
+			"price": crud.Number().Min(0),
+			"tags":  crud.Array().Items(crud.String()),
+		})),
+	})
+	var items []string
+	for i := 1; i <= 100; i++ {
+		items = append(items, fmt.Sprintf(`{"id":%v,"sku":"SKU-%v","price":%v.5,"tags":["a","b"]}`, i, i, i))
+	}
+	return field, []byte(`{"name":"order","items":[` + strings.Join(items, ",") + `]}`)
+}
+
+func BenchmarkAdapter(b *testing.B) {
+	field, data := benchmarkBody()
+	// without the logger of New, it would be most of what is measured
+	adapter := &Adapter{Echo: echo.New()}
+	router := crud.NewRouter("Widget API", "1.0.0", adapter)
+	err := router.Add(crud.Spec{
+		Method: "POST",
+		Path:   "/orders",
+		Handler: func(c echo.Context) error {
+			_ = crud.InputsFromContext(c.Request().Context()).Body
+			return c.NoContent(http.StatusOK)
+		},
+		Validate: crud.Validate{Body: field},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := httptest.NewRequest("POST", "/orders", bytes.NewReader(data))
+		r.Header.Set("content-type", "application/json")
+		w := httptest.NewRecorder()
+		adapter.Echo.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			b.Fatal(w.Code, w.Body.String())
+		}
+	}
 }
@@ -1,12 +1,11 @@
 package adapter
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/jakecoffman/crud"
-	"io/ioutil"
+	"mime/multipart"
+	"net/http"
 	"net/url"
 	"reflect"
 )
@@ -37,6 +36,10 @@ func (a *Adapter) Install(r *crud.Router, spec *crud.Spec) error {
 		return fmt.Errorf("unexpected PreHandlers type: %v", reflect.TypeOf(spec.Handler))
 	}
 
+	if validate := r.ResponseValidator(spec); validate != nil {
+		handlers = append(handlers, validateResponse(validate))
+	}
+
 	switch v := spec.Handler.(type) {
 	case nil:
 		return fmt.Errorf("handler must not be nil")
@@ -44,8 +47,10 @@ func (a *Adapter) Install(r *crud.Router, spec *crud.Spec) error {
 		handlers = append(handlers, v)
 	case func(*gin.Context):
 		handlers = append(handlers, v)
+	case http.Handler:
+		handlers = append(handlers, gin.WrapH(v))
 	default:
-		return fmt.Errorf("handler must be gin.HandlerFunc or func(*gin.Context), got %v", reflect.TypeOf(spec.Handler))
+		return fmt.Errorf("handler must be gin.HandlerFunc, func(*gin.Context) or http.Handler, got %v", reflect.TypeOf(spec.Handler))
 	}
 
 	a.Engine.Handle(spec.Method, swaggerToGinPattern(spec.Path), handlers...)
@@ -87,26 +92,118 @@ func wrap(r *crud.Router, spec *crud.Spec) gin.HandlerFunc {
 			}
 		}
 
+		var decoded *crud.Body
 		if val.Body.Initialized() && val.Body.Kind() != crud.KindFile {
-			if err := c.Bind(&body); err != nil {
-				c.AbortWithStatusJSON(400, err.Error())
+			var err error
+			if decoded, err = r.DecodeBody(c.Request, spec); err != nil {
+				r.RenderError(c.Writer, c.Request, spec, fmt.Errorf("failure decoding body: %w", err))
+				c.Abort()
 				return
 			}
-			defer func() {
-				data, _ := json.Marshal(body)
-				c.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
-			}()
+			// released once the rest of the handlers are done with the body, see c.Next below
+			defer decoded.Release()
+			body = decoded.Value
 		}
 
 		if val.Query.Initialized() {
 			query = c.Request.URL.Query()
-			defer func() {
-				c.Request.URL.RawQuery = query.Encode()
-			}()
 		}
 
-		if err := r.Validate(val, query, body, path); err != nil {
-			c.AbortWithStatusJSON(400, err.Error())
+		var form url.Values
+		var files map[string][]*multipart.FileHeader
+		if val.FormData.Initialized() {
+			var err error
+			if form, files, err = crud.ParseForm(c.Request); err != nil {
+				r.RenderError(c.Writer, c.Request, spec, fmt.Errorf("failure decoding form: %w", err))
+				c.Abort()
+				return
+			}
+		}
+
+		inputs := &crud.Inputs{Path: path, Query: query, Header: c.Request.Header, Body: body, Form: form, Files: files}
+		if err := r.ValidateInputs(val, inputs, spec.Options...); err != nil {
+			r.RenderError(c.Writer, c.Request, spec, err)
+			c.Abort()
+			return
+		}
+
+		// Validate can strip values that are not valid, so we rewrite them after validation
+		// is complete. The body is only encoded again if it changed.
+		if decoded != nil {
+			c.Request.Body = decoded.Reader(inputs.BodyChanged)
+		}
+		if query != nil {
+			c.Request.URL.RawQuery = query.Encode()
 		}
+		if form != nil {
+			// c.Request.Form is rebuilt from the validated PostForm and query on next use
+			c.Request.Form = nil
+		}
+		c.Request = c.Request.WithContext(crud.ContextWithInputs(c.Request.Context(), inputs))
+
+		// run the rest of the handlers here, so the body is released after them
+		c.Next()
 	}
 }
+
+// validateResponse runs the rest of the handlers with a writer that goes through the
+// crud response validation middleware, so the response can be checked before it's sent.
+func validateResponse(validate func(http.Handler) http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		original := c.Writer
+		validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Writer = &responseWriter{ResponseWriter: original, w: w, status: http.StatusOK, size: -1}
+			c.Request = r
+			c.Next()
+		})).ServeHTTP(original, c.Request)
+		c.Writer = original
+	}
+}
+
+// responseWriter satisfies gin.ResponseWriter while writing to the validation middleware.
+type responseWriter struct {
+	gin.ResponseWriter
+	w      http.ResponseWriter
+	status int
+	size   int
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.w.Header()
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if !rw.Written() {
+		rw.status = status
+	}
+}
+
+func (rw *responseWriter) WriteHeaderNow() {
+	if !rw.Written() {
+		rw.size = 0
+		rw.w.WriteHeader(rw.status)
+	}
+}
+
+func (rw *responseWriter) Write(data []byte) (int, error) {
+	rw.WriteHeaderNow()
+	n, err := rw.w.Write(data)
+	rw.size += n
+	return n, err
+}
+
+func (rw *responseWriter) WriteString(s string) (int, error) {
+	return rw.Write([]byte(s))
+}
+
+func (rw *responseWriter) Status() int {
+	return rw.status
+}
+
+func (rw *responseWriter) Size() int {
+	return rw.size
+}
+
+func (rw *responseWriter) Written() bool {
+	return rw.size != -1
+}
@@ -1,6 +1,18 @@
 package adapter
 
-import "testing"
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jakecoffman/crud"
+	"github.com/jakecoffman/crud/option"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
 
 func TestSwaggerToGin(t *testing.T) {
 	if "/widgets/:id" != swaggerToGinPattern("/widgets/{id}") {
@@ -10,3 +22,241 @@ func TestSwaggerToGin(t *testing.T) {
 		t.Error(swaggerToGinPattern("/widgets/{id}/sub/{subId}"))
 	}
 }
+
+func TestResponseValidation(t *testing.T) {
+	adapter := New()
+	router := crud.NewRouter("Widget API", "1.0.0", adapter, option.ValidateResponses(1))
+
+	err := router.Add(crud.Spec{
+		Method: "GET",
+		Path:   "/widgets/{id}",
+		Handler: func(c *gin.Context) {
+			if c.Param("id") == "1" {
+				c.JSON(200, gin.H{"id": 1})
+				return
+			}
+			c.JSON(200, gin.H{"id": "wrong"})
+		},
+		Validate: crud.Validate{
+			Path: crud.Object(map[string]crud.Field{
+				"id": crud.Integer().Required(),
+			}),
+		},
+		Responses: map[string]crud.Response{
+			"200": {
+				Schema: crud.JsonSchema{
+					Type:       crud.KindObject,
+					Properties: map[string]crud.JsonSchema{"id": {Type: crud.KindInteger}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", "/widgets/1", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"id":1}` {
+		t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", "/widgets/2", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+
+	// validation errors are documented so they pass too
+	w = httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", "/widgets/a", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	t.Run("strips values from the body", func(t *testing.T) {
+		adapter := New()
+		router := crud.NewRouter("title", "1.0", adapter)
+		err := router.Add(crud.Spec{
+			Method: "POST",
+			Path:   "/widgets",
+			Handler: func(c *gin.Context) {
+				// reflect the body back for ease of testing
+				_, _ = io.Copy(c.Writer, c.Request.Body)
+			},
+			Validate: crud.Validate{
+				Body: crud.Object(map[string]crud.Field{
+					"value":    crud.String().Required(),
+					"quantity": crud.Integer().Default(1),
+				}),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest("POST", "/widgets", strings.NewReader(`{"value": "hello", "unexpected": 1}`))
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		// unexpected property should be stripped and the default applied
+		if w.Body.String() != `{"quantity":1,"value":"hello"}` {
+			t.Errorf("unexpected body %q", w.Body.String())
+		}
+
+		// an unchanged body is passed on as it was sent
+		r = httptest.NewRequest("POST", "/widgets", strings.NewReader(`{"value": "hello", "quantity": 2}`))
+		w = httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+
+		if w.Body.String() != `{"value": "hello", "quantity": 2}` {
+			t.Errorf("unexpected body %q", w.Body.String())
+		}
+	})
+
+	t.Run("validates headers", func(t *testing.T) {
+		adapter := New()
+		router := crud.NewRouter("title", "1.0", adapter)
+		err := router.Add(crud.Spec{
+			Method: "GET",
+			Path:   "/widgets",
+			Handler: func(c *gin.Context) {
+				c.String(http.StatusOK, c.GetHeader("X-Limit"))
+			},
+			Validate: crud.Validate{
+				Header: crud.Object(map[string]crud.Field{
+					"X-Token": crud.String().Required(),
+					"X-Limit": crud.Integer().Max(10).Default(5),
+				}),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest("GET", "/widgets", nil)
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		r = httptest.NewRequest("GET", "/widgets", nil)
+		r.Header.Set("X-Token", "secret")
+		w = httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		// default should be applied
+		if w.Body.String() != "5" {
+			t.Errorf("unexpected body %q", w.Body.String())
+		}
+	})
+
+	t.Run("validates multipart forms", func(t *testing.T) {
+		adapter := New()
+		router := crud.NewRouter("title", "1.0", adapter)
+		err := router.Add(crud.Spec{
+			Method: "POST",
+			Path:   "/widgets",
+			Handler: func(c *gin.Context) {
+				c.String(http.StatusOK, c.PostForm("name")+c.PostForm("unexpected"))
+			},
+			Validate: crud.Validate{
+				FormData: crud.Object(map[string]crud.Field{
+					"name":  crud.String().Required(),
+					"image": crud.File().Required().MimeTypes("image/png"),
+				}),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, newMultipartRequest("not an image"))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		w = httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, newMultipartRequest("\x89PNG\r\n\x1a\n"))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d: %v", http.StatusOK, w.Code, w.Body.String())
+		}
+		// unexpected value should be stripped
+		if w.Body.String() != "bob" {
+			t.Errorf("unexpected body %q", w.Body.String())
+		}
+	})
+}
+
+// newMultipartRequest posts a form with a name, an unexpected value and the image.
+func newMultipartRequest(image string) *http.Request {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("name", "bob")
+	_ = mw.WriteField("unexpected", "value")
+	fw, _ := mw.CreateFormFile("image", "image.png")
+	_, _ = io.WriteString(fw, image)
+	_ = mw.Close()
+	r := httptest.NewRequest("POST", "/widgets", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+// benchmarkBody is a body with a few hundred values, like the larger ones seen in practice.
+func benchmarkBody() (crud.Field, []byte) {
+	field := crud.Object(map[string]crud.Field{
+		"name": crud.String().Required(),
+		"items": crud.Array().Items(crud.Object(map[string]crud.Field{
+			"id":    crud.Integer().Required().Min(1),
+			"sku":   crud.String().Pattern("^[A-Z0-9-]+$"),
+			"price": crud.Number().Min(0),
+			"tags":  crud.Array().Items(crud.String()),
+		})),
+	})
+	var items []string
+	for i := 1; i <= 100; i++ {
+		items = append(items, fmt.Sprintf(`{"id":%v,"sku":"SKU-%v","price":%v.5,"tags":["a","b"]}`, i, i, i))
+	}
+	return field, []byte(`{"name":"order","items":[` + strings.Join(items, ",") + `]}`)
+}
+
+func BenchmarkAdapter(b *testing.B) {
+	gin.SetMode(gin.ReleaseMode)
+	field, data := benchmarkBody()
+	adapter := New()
+	router := crud.NewRouter("Widget API", "1.0.0", adapter)
+	err := router.Add(crud.Spec{
+		Method: "POST",
+		Path:   "/orders",
+		Handler: func(c *gin.Context) {
+			_ = crud.InputsFromContext(c.Request.Context()).Body
+		},
+		Validate: crud.Validate{Body: field},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, httptest.NewRequest("POST", "/orders", bytes.NewReader(data)))
+		if w.Code != http.StatusOK {
+			b.Fatal(w.Code, w.Body.String())
+		}
+	}
+}
@@ -1,12 +1,11 @@
 package adapter
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/jakecoffman/crud"
-	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"reflect"
@@ -52,6 +51,9 @@ func (a *Adapter) Install(r *crud.Router, spec *crud.Spec) error {
 	default:
 		return fmt.Errorf("handler must be http.HandlerFunc, got %v", reflect.TypeOf(spec.Handler))
 	}
+	if validate := r.ResponseValidator(spec); validate != nil {
+		finalHandler = validate(finalHandler)
+	}
 
 	// without Subrouter, Use would affect all other routes too
 	subRouter := a.Engine.Path(spec.Path).Methods(spec.Method).Subrouter()
@@ -92,14 +94,16 @@ func validateHandlerMiddleware(router *crud.Router, spec *crud.Spec) mux.Middlew
 				}
 			}
 
-			var rewriteBody bool
+			var decoded *crud.Body
 			if val.Body.Initialized() && val.Body.Kind() != crud.KindFile {
-				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-					w.WriteHeader(400)
-					_ = json.NewEncoder(w).Encode("failure decoding body: " + err.Error())
+				var err error
+				if decoded, err = router.DecodeBody(r, spec); err != nil {
+					router.RenderError(w, r, spec, fmt.Errorf("failure decoding body: %w", err))
 					return
 				}
-				rewriteBody = true
+				// the handler is done with the body once this returns
+				defer decoded.Release()
+				body = decoded.Value
 			}
 
 			var rewriteQuery bool
@@ -108,26 +112,39 @@ func validateHandlerMiddleware(router *crud.Router, spec *crud.Spec) mux.Middlew
 				rewriteQuery = true
 			}
 
-			if err := router.Validate(val, query, body, path); err != nil {
-				w.WriteHeader(400)
-				_ = json.NewEncoder(w).Encode(err.Error())
+			var form url.Values
+			var files map[string][]*multipart.FileHeader
+			if val.FormData.Initialized() {
+				var err error
+				if form, files, err = crud.ParseForm(r); err != nil {
+					router.RenderError(w, r, spec, fmt.Errorf("failure decoding form: %w", err))
+					return
+				}
+			}
+
+			inputs := &crud.Inputs{Path: path, Query: query, Header: r.Header, Body: body, Form: form, Files: files}
+			if err := router.ValidateInputs(val, inputs, spec.Options...); err != nil {
+				router.RenderError(w, r, spec, err)
 				return
 			}
 
 			// Validate can strip values that are not valid, so we rewrite them
 			// after validation is complete. Can't use defer as in other adapters
 			// because next.ServeHTTP calls the next handler and defer hasn't
-			// run yet.
-			if rewriteBody {
-				data, _ := json.Marshal(body)
+			// run yet. The body is only encoded again if it changed.
+			if decoded != nil {
 				_ = r.Body.Close()
-				r.Body = ioutil.NopCloser(bytes.NewReader(data))
+				r.Body = decoded.Reader(inputs.BodyChanged)
 			}
 			if rewriteQuery {
 				r.URL.RawQuery = query.Encode()
 			}
+			if form != nil {
+				// r.Form is rebuilt from the validated r.PostForm and query on next use
+				r.Form = nil
+			}
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(crud.ContextWithInputs(r.Context(), inputs)))
 		})
 	}
 }
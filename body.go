@@ -0,0 +1,119 @@
+package crud
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// bufferPool holds the buffers request bodies are read into and encoded to.
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// maxPooledBuffer keeps the buffers of unusually large bodies out of the pool.
+const maxPooledBuffer = 1 << 20
+
+// Body is a JSON request body decoded for validation. It keeps the bytes it was read from, so
+// the handler gets them as they were unless validation stripped or defaulted something.
+type Body struct {
+	// Value is the decoded body, validation changes it in place.
+	Value interface{}
+
+	raw, encoded *bytes.Buffer
+}
+
+// DecodeBody reads the JSON body of the request into a pooled buffer and decodes it, with
+// numbers as json.Number. The limits set on the router or spec are checked before it is decoded,
+// see option.MaxBodyBytes, and so is option.StrictJSON. Adapters call Release once the handler is done with the body.
+func (r *Router) DecodeBody(req *http.Request, spec *Spec) (*Body, error) {
+	return decodeBody(req.Body, req.ContentLength, r.settings.apply(spec.Options...))
+}
+
+// decodeBody does the work of DecodeBody. The length is the Content-Length, or -1 if it is unknown.
+func decodeBody(body io.Reader, length int64, s settings) (*Body, error) {
+	l := s.limits
+	if l.bytes > 0 && length > l.bytes {
+		return nil, l.tooLarge()
+	}
+	if l.bytes > 0 {
+		// one more byte, to tell a body of the limit from a larger one
+		body = io.LimitReader(body, l.bytes+1)
+	}
+	b := &Body{raw: getBuffer()}
+	if _, err := b.raw.ReadFrom(body); err != nil {
+		b.Release()
+		return nil, err
+	}
+	if l.bytes > 0 && int64(b.raw.Len()) > l.bytes {
+		b.Release()
+		return nil, l.tooLarge()
+	}
+	if err := scan(b.raw.Bytes(), l, s.strictJSON); err != nil {
+		b.Release()
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b.raw.Bytes()))
+	// numbers are kept as written, so integers past 2^53 are validated and passed on exactly
+	decoder.UseNumber()
+	if err := decoder.Decode(&b.Value); err != nil {
+		b.Release()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Reader returns the body for the handler: the bytes that were read, or the value encoded
+// when the handler first reads it if changed is true. See Inputs.BodyChanged.
+func (b *Body) Reader(changed bool) io.ReadCloser {
+	if !changed {
+		return io.NopCloser(bytes.NewReader(b.raw.Bytes()))
+	}
+	return &encodingReader{body: b}
+}
+
+// Release puts the buffers of the body back in the pool. The body must not be read after,
+// so adapters call it once the handler returned. Release does nothing on a nil Body.
+func (b *Body) Release() {
+	if b == nil {
+		return
+	}
+	putBuffer(b.raw)
+	putBuffer(b.encoded)
+	b.raw, b.encoded = nil, nil
+}
+
+// encodingReader encodes the value of the body on the first read, handlers that get the
+// value from the context never pay for it.
+type encodingReader struct {
+	body   *Body
+	reader *bytes.Reader
+}
+
+func (e *encodingReader) Read(p []byte) (int, error) {
+	if e.reader == nil {
+		e.body.encoded = getBuffer()
+		if err := json.NewEncoder(e.body.encoded).Encode(e.body.Value); err != nil {
+			return 0, err
+		}
+		// without the newline Encode adds, like json.Marshal
+		e.reader = bytes.NewReader(bytes.TrimSuffix(e.body.encoded.Bytes(), []byte("\n")))
+	}
+	return e.reader.Read(p)
+}
+
+func (e *encodingReader) Close() error {
+	return nil
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf != nil && buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
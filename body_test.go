@@ -0,0 +1,84 @@
+package crud
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBody(t *testing.T) {
+	const raw = `{ "b": 2, "a": 1 }`
+	body, err := decodeBody(strings.NewReader(raw), -1, settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Release()
+
+	// unchanged bodies are passed on as they were read
+	data, _ := io.ReadAll(body.Reader(false))
+	if string(data) != raw {
+		t.Errorf("expected %v got %s", raw, data)
+	}
+
+	body.Value.(map[string]interface{})["c"] = 3
+	data, _ = io.ReadAll(body.Reader(true))
+	if string(data) != `{"a":1,"b":2,"c":3}` {
+		t.Errorf("unexpected body %s", data)
+	}
+
+	if _, err = decodeBody(strings.NewReader(`{"a":`), -1, settings{}); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	// a nil body can be released, adapters defer it before knowing
+	var nothing *Body
+	nothing.Release()
+}
+
+func TestBodyChanged(t *testing.T) {
+	body := Object(map[string]Field{
+		"name":  String().Required(),
+		"count": Integer().Default(1),
+		"kind":  OneOf(Object(map[string]Field{"a": Integer()}).Unknown(false), Object(map[string]Field{"b": Integer().Required(), "c": Integer().Default(2)}).Unknown(false)),
+	}).Strip(true)
+
+	tests := []struct {
+		Input   string
+		Changed bool
+	}{
+		{Input: `{"name":"a","count":2}`, Changed: false},
+		{Input: `{"name":"a","count":2,"kind":{"a":1}}`, Changed: false},
+		{Input: `{"name":"a"}`, Changed: true},
+		{Input: `{"name":"a","count":2,"extra":true}`, Changed: true},
+		{Input: `{"name":"a","count":2,"kind":{"b":1}}`, Changed: true},
+	}
+
+	for _, test := range tests {
+		adapter := NewServeMuxAdapter()
+		router := NewRouter("title", "1.0", adapter)
+		var changed bool
+		err := router.Add(Spec{
+			Method: "POST",
+			Path:   "/widgets",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				changed = InputsFromContext(r.Context()).BodyChanged
+				_, _ = io.Copy(w, r.Body)
+			},
+			Validate: Validate{Body: body},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, httptest.NewRequest("POST", "/widgets", strings.NewReader(test.Input)))
+		if w.Code != http.StatusOK || changed != test.Changed {
+			t.Errorf("%v: expected changed %v got %v %v %v", test.Input, test.Changed, changed, w.Code, w.Body.String())
+		}
+		if !changed && w.Body.String() != test.Input {
+			t.Errorf("%v: expected the body as it was, got %v", test.Input, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package crud
+
+import "maps"
+
+// compiled is a Validate with the settings of the router resolved into its fields. Router.Add
+// compiles each spec once, requests only read it so it's shared by all of them.
+type compiled struct {
+	// the settings it was compiled with, validating with others compiles again
+	stripUnknown, allowUnknown bool
+
+	path, query, header, cookie, form, body Field
+
+	queryAllowUnknown, queryStripUnknown   bool
+	headerAllowUnknown, headerStripUnknown bool
+	formAllowUnknown, formStripUnknown     bool
+
+	// responses are those documented for the spec, see CheckResponse
+	responses map[string]Response
+}
+
+// compile resolves the settings every field inherits and the router's defaults for the inputs of val.
+func compile(val Validate, s settings) *compiled {
+	v := &compiled{
+		stripUnknown: s.stripUnknown,
+		allowUnknown: s.allowUnknown,
+		path:         resolve(val.Path),
+		query:        resolve(val.Query),
+		header:       resolve(val.Header),
+		cookie:       resolve(val.Cookie),
+		form:         resolve(val.FormData),
+	}
+
+	v.queryAllowUnknown = s.allowUnknown
+	if val.Query.unknown != nil {
+		v.queryAllowUnknown = *val.Query.unknown
+	}
+	v.queryStripUnknown = s.stripUnknown
+	if val.Query.strip != nil {
+		v.queryStripUnknown = *val.Query.strip
+	}
+
+	// requests carry many headers the spec will never mention (User-Agent, Accept, etc.)
+	// so the router defaults are not used here, only settings made on the field itself.
+	v.headerAllowUnknown = val.Header.isAllowUnknown()
+	v.headerStripUnknown = val.Header.strip != nil && *val.Header.strip
+
+	v.formAllowUnknown = s.allowUnknown
+	if val.FormData.unknown != nil {
+		v.formAllowUnknown = *val.FormData.unknown
+	}
+	v.formStripUnknown = s.stripUnknown
+	if val.FormData.strip != nil {
+		v.formStripUnknown = *val.FormData.strip
+	}
+
+	if val.Body.Initialized() {
+		// use router defaults if the object doesn't have anything set
+		body := val.Body
+		if body.strip == nil {
+			body = body.Strip(s.stripUnknown)
+		}
+		if body.unknown == nil {
+			body = body.Unknown(s.allowUnknown)
+		}
+		// ensure Required() since it's confusing and error-prone otherwise
+		body = body.Required()
+		v.body = resolve(body)
+	}
+	return v
+}
+
+// resolve returns a copy of the field where everything it holds has inherited its settings. Nothing
+// is shared with the field, so it can't be changed through it.
+func resolve(f Field) Field {
+	if f.obj != nil {
+		obj := make(map[string]Field, len(f.obj))
+		for name, child := range f.obj {
+			obj[name] = resolve(child.inherit(&f))
+		}
+		f.obj = obj
+		f.names = sortedKeys(obj)
+	}
+	if f.arr != nil {
+		item := resolve(f.arr.inherit(&f))
+		f.arr = &item
+	}
+	if f.values != nil {
+		values := resolve(f.values.inherit(&f))
+		f.values = &values
+	}
+	if f.of != nil {
+		of := make([]Field, len(f.of))
+		for i := range f.of {
+			of[i] = resolve(f.of[i].inherit(&f))
+		}
+		f.of = of
+	}
+	if f.variants != nil {
+		variants := maps.Clone(f.variants)
+		for value, variant := range variants {
+			variants[value] = resolve(variant.inherit(&f))
+		}
+		f.variants = variants
+	}
+	return f
+}
+
+// inherit returns a copy of the field with the strip and unknown settings of its parent,
+// unless it has its own.
+func (f Field) inherit(parent *Field) Field {
+	if f.strip == nil {
+		f.strip = parent.strip
+	}
+	if f.unknown == nil {
+		f.unknown = parent.unknown
+	}
+	return f
+}
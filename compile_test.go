@@ -0,0 +1,103 @@
+package crud
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jakecoffman/crud/option"
+)
+
+// These tests are meant for go test -race, they check the schema isn't changed by requests.
+
+func TestCompile_ParallelRequests(t *testing.T) {
+	item := Object(map[string]Field{
+		"name": String().Required(),
+		"tags": Array().Items(Object(map[string]Field{
+			"key":   String().Required(),
+			"value": String().Default("none"),
+		})),
+		"kind": OneOf(Object(map[string]Field{"a": Integer()}).Unknown(false), Object(map[string]Field{"b": Integer()}).Unknown(false)),
+	})
+	adapter := NewServeMuxAdapter()
+	router := NewRouter("title", "1.0", adapter)
+	err := router.Add(Spec{
+		Method: "POST",
+		Path:   "/items",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(w, r.Body)
+		},
+		Validate: Validate{
+			Query: Object(map[string]Field{"ids": Array().Items(Integer()).Default([]interface{}{1, 2})}),
+			Body:  Object(map[string]Field{"items": Array().Items(item).Strip(true)}),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"items":[{"name":"n%v","extra":1,"tags":[{"key":"k","extra":2}],"kind":{"b":%v}}]}`, i, i)
+			r := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			adapter.Engine.ServeHTTP(w, r)
+
+			expected := fmt.Sprintf(`{"items":[{"kind":{"b":%v},"name":"n%v","tags":[{"key":"k","value":"none"}]}]}`, i, i)
+			if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != expected {
+				t.Errorf("expected %v got %v %v", expected, w.Code, w.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCompile_ParallelFieldValidate(t *testing.T) {
+	// items of arrays used to be changed by Validate to inherit the settings
+	field := Array().Items(Array().Items(Object(map[string]Field{"a": String()}))).Strip(true).Unknown(false)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			input := []interface{}{[]interface{}{map[string]interface{}{"a": "x"}}}
+			if err := field.Validate(input); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if field.arr.strip != nil || field.arr.unknown != nil {
+		t.Errorf("expected the items to be left as they were")
+	}
+}
+
+func TestCompile_Options(t *testing.T) {
+	body := Object(map[string]Field{"a": String()})
+	router := NewRouter("title", "1.0", &TestAdapter{})
+	val := Validate{Body: body}
+	val.compiled = compile(val, router.settings)
+
+	input := map[string]interface{}{"a": "x", "b": "y"}
+	if err := router.ValidateInputs(val, &Inputs{Body: input}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := input["b"]; ok {
+		t.Errorf("expected the router default to strip b")
+	}
+
+	// options other than those it was compiled with are still honored
+	input = map[string]interface{}{"a": "x", "b": "y"}
+	if err := router.ValidateInputs(val, &Inputs{Body: input}, option.StripUnknown(false), option.AllowUnknown(false)); err == nil {
+		t.Errorf("expected b to be rejected")
+	}
+}
@@ -0,0 +1,298 @@
+package crud
+
+import (
+	"encoding/json"
+	"slices"
+)
+
+// OneOf creates a field that is valid when exactly one of the fields is, e.g. a payment that is
+// either a card or a bank account.
+func OneOf(fields ...Field) Field {
+	return composite(KindOneOf, fields)
+}
+
+// AnyOf creates a field that is valid when at least one of the fields is.
+func AnyOf(fields ...Field) Field {
+	return composite(KindAnyOf, fields)
+}
+
+// AllOf creates a field that is valid when all the fields are, e.g. to extend an object with more
+// properties. Unknown properties are those not in any of the objects.
+func AllOf(fields ...Field) Field {
+	return composite(KindAllOf, fields)
+}
+
+// Not creates a field that is valid when the field isn't.
+func Not(field Field) Field {
+	return composite(KindNot, []Field{field})
+}
+
+// Discriminated creates a field that is one of the variants, chosen by the value of the property.
+// Validation errors point into the chosen variant rather than saying none matched. In variants that
+// are objects the property is required and has the value choosing the variant as its only enum
+// value, it is added if they don't have it. Make the variants Models so the mapping of the
+// discriminator in the OpenAPI document can reference them.
+func Discriminated(property string, variants map[string]Field) Field {
+	if len(variants) == 0 {
+		panic("Discriminated needs at least one variant")
+	}
+	f := Field{kind: KindOneOf, discriminator: property, variants: map[string]Field{}}
+	for _, value := range sortedKeys(variants) {
+		variant := variants[value]
+		if variant.kind == KindObject {
+			tag, ok := variant.obj[property]
+			if !ok {
+				tag = String()
+			}
+			// the documentation of each variant says which value chooses it
+			tag.enum = enum{value}
+			if tag._default == nil {
+				tag = tag.Required()
+			}
+			obj := map[string]Field{property: tag}
+			for name, child := range variant.obj {
+				if name != property {
+					obj[name] = child
+				}
+			}
+			variant.obj = obj
+		}
+		f.variants[value] = variant
+		f.of = append(f.of, variant)
+	}
+	return f
+}
+
+func composite(kind string, fields []Field) Field {
+	if len(fields) == 0 {
+		panic(kind + " needs at least one field")
+	}
+	return Field{kind: kind, of: fields}
+}
+
+// validateComposite validates the input against the fields combined by OneOf, AnyOf, AllOf or Not.
+// Fields that are tried are validated against copies, so only a match strips or defaults the input.
+func validateComposite(loc *location, field *Field, input interface{}, c *collector) error {
+	branches := make([]Field, len(field.of))
+	for i, branch := range field.of {
+		// branches inherit the settings like children do
+		if branch.strip == nil {
+			branch.strip = field.strip
+		}
+		if branch.unknown == nil {
+			branch.unknown = field.unknown
+		}
+		branches[i] = branch
+	}
+
+	if field.discriminator != "" {
+		return validateDiscriminated(loc, field, input, c)
+	}
+
+	switch field.kind {
+	case KindAllOf:
+		known := map[string]Field{}
+		for i := range branches {
+			for name, property := range branches[i].obj {
+				known[name] = property
+			}
+			// each object only knows its own properties, so unknown ones are handled below
+			branch := ignoreUnknown(branches[i])
+			if err := validateObject(loc, &branch, input, c); err != nil {
+				return err
+			}
+		}
+		if v, ok := input.(map[string]interface{}); ok {
+			return checkUnknown(loc, field, v, known, c)
+		}
+		return nil
+	case KindNot:
+		if candidate, _ := matches(&branches[0], input); candidate == nil {
+			return nil
+		}
+		return c.add(newValidationError(InBody, loc.pointer(), field, input, ErrNot))
+	}
+
+	var matched []int
+	var result interface{}
+	var changed bool
+	for i := range branches {
+		if candidate, candidateChanged := matches(&branches[i], input); candidate != nil {
+			if matched == nil {
+				result, changed = candidate, candidateChanged
+			}
+			matched = append(matched, i)
+			if field.kind == KindAnyOf {
+				break
+			}
+		}
+	}
+	switch {
+	case len(matched) == 1:
+		if changed {
+			replaceJSON(input, result)
+			c.changed = true
+		}
+		return nil
+	case len(matched) > 1:
+		return c.add(&ValidationError{In: InBody, Pointer: loc.pointer(), Rule: "oneOf", Limit: matched, Value: input, Err: ErrOneOf})
+	}
+
+	// when only one of the fields has the type of the input, its errors say more than ours
+	var candidates []int
+	for i := range branches {
+		if sameKind(&branches[i], input) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 1 {
+		return validateObject(loc, &branches[candidates[0]], input, c)
+	}
+	if field.kind == KindAnyOf {
+		return c.add(newValidationError(InBody, loc.pointer(), field, input, ErrAnyOf))
+	}
+	return c.add(newValidationError(InBody, loc.pointer(), field, input, ErrOneOf))
+}
+
+// validateDiscriminated validates the input against the variant its discriminator property chooses.
+func validateDiscriminated(loc *location, field *Field, input interface{}, c *collector) error {
+	v, ok := input.(map[string]interface{})
+	if !ok {
+		object := Object(nil)
+		return c.add(newValidationError(InBody, loc.pointer(), &object, input, ErrWrongType))
+	}
+	var values []interface{}
+	for _, value := range sortedKeys(field.variants) {
+		values = append(values, value)
+	}
+	tag := String().Required().Enum(values...)
+	name, _ := v[field.discriminator].(string)
+	variant, ok := field.variants[name]
+	if !ok {
+		err := ErrEnumNotFound
+		if v[field.discriminator] == nil {
+			err = ErrRequired
+		}
+		return c.add(newValidationError(InBody, loc.property(field.discriminator).pointer(), &tag, v[field.discriminator], err))
+	}
+	// the variant inherits the settings like children do
+	if variant.strip == nil {
+		variant.strip = field.strip
+	}
+	if variant.unknown == nil {
+		variant.unknown = field.unknown
+	}
+	return validateObject(loc, &variant, input, c)
+}
+
+// matches validates a copy of the input and returns it if it is valid, otherwise nil. Changed is
+// true if validating the copy stripped or defaulted it.
+func matches(field *Field, input interface{}) (candidate interface{}, changed bool) {
+	candidate = copyJSON(input)
+	c := &collector{}
+	if validateObject(nil, field, candidate, c) != nil {
+		return nil, false
+	}
+	return candidate, c.changed
+}
+
+// ignoreUnknown returns a copy of an object field that neither rejects nor strips unknown properties,
+// without changing what its children inherit.
+func ignoreUnknown(field Field) Field {
+	if field.kind != KindObject {
+		return field
+	}
+	obj := make(map[string]Field, len(field.obj))
+	for name, child := range field.obj {
+		if child.strip == nil {
+			child.strip = field.strip
+		}
+		if child.unknown == nil {
+			child.unknown = field.unknown
+		}
+		obj[name] = child
+	}
+	field.obj = obj
+	return field.Strip(false).Unknown(true)
+}
+
+// checkUnknown rejects or strips the properties of v that aren't known, depending on the field's settings.
+func checkUnknown(loc *location, field *Field, v map[string]interface{}, known map[string]Field, c *collector) error {
+	if !field.isAllowUnknown() {
+		// only the unknown properties are sorted, usually there are none
+		var unknown []string
+		for key := range v {
+			if _, ok := known[key]; !ok {
+				unknown = append(unknown, key)
+			}
+		}
+		slices.Sort(unknown)
+		for _, key := range unknown {
+			if err := c.add(newValidationError(InBody, loc.property(key).pointer(), field, v[key], ErrUnknown)); err != nil {
+				return err
+			}
+		}
+	}
+	if field.isStripUnknown() {
+		for key := range v {
+			if _, ok := known[key]; !ok {
+				delete(v, key)
+				c.changed = true
+			}
+		}
+	}
+	return nil
+}
+
+// sameKind returns true if the input has the JSON type of the field. Combined fields could have any type.
+func sameKind(field *Field, input interface{}) bool {
+	switch v := input.(type) {
+	case map[string]interface{}:
+		return field.kind == KindObject || len(field.of) > 0
+	case []interface{}:
+		return field.kind == KindArray || len(field.of) > 0
+	case string:
+		return field.kind == KindString || len(field.of) > 0
+	case bool:
+		return field.kind == KindBoolean || len(field.of) > 0
+	case float64:
+		return field.kind == KindNumber || (field.kind == KindInteger && v == float64(int64(v))) || len(field.of) > 0
+	case int:
+		return field.kind == KindNumber || field.kind == KindInteger || len(field.of) > 0
+	case json.Number:
+		return field.kind == KindNumber || (field.kind == KindInteger && integral(v)) || len(field.of) > 0
+	}
+	return false
+}
+
+// copyJSON makes a deep copy of decoded JSON.
+func copyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = copyJSON(value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = copyJSON(value)
+		}
+		return s
+	}
+	return v
+}
+
+// replaceJSON makes dst hold what src holds, for maps and slices which are changed in place.
+func replaceJSON(dst, src interface{}) {
+	switch dst := dst.(type) {
+	case map[string]interface{}:
+		clear(dst)
+		for key, value := range src.(map[string]interface{}) {
+			dst[key] = value
+		}
+	case []interface{}:
+		copy(dst, src.([]interface{}))
+	}
+}
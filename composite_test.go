@@ -0,0 +1,286 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var (
+	card = Object(map[string]Field{
+		"number": String().Required().Pattern(`^\d{16}$`),
+		"cvc":    String().Required(),
+	})
+	bank = Object(map[string]Field{
+		"iban": String().Required(),
+	})
+)
+
+func TestOneOf(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+	payment := Object(map[string]Field{
+		"amount": Number().Required(),
+		"source": OneOf(card, bank).Required(),
+	})
+
+	tests := []struct {
+		Input    string
+		Expected error
+		Pointer  string
+	}{
+		{Input: `{"amount":1,"source":{"number":"1234123412341234","cvc":"123"}}`},
+		{Input: `{"amount":1,"source":{"iban":"DE00"}}`},
+		{Input: `{"amount":1}`, Expected: ErrRequired, Pointer: "/source"},
+		{Input: `{"amount":1,"source":{"number":"1"}}`, Expected: ErrOneOf, Pointer: "/source"},
+		{Input: `{"amount":1,"source":{"iban":"DE00","number":"1234123412341234","cvc":"123"}}`, Expected: ErrOneOf, Pointer: "/source"},
+		{Input: `{"amount":1,"source":"card"}`, Expected: ErrOneOf, Pointer: "/source"},
+	}
+
+	for i, test := range tests {
+		var body interface{}
+		_ = json.Unmarshal([]byte(test.Input), &body)
+		err := r.Validate(Validate{Body: payment.Unknown(true).Strip(false)}, nil, body, nil)
+		if !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", i, test.Expected, err)
+		}
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) && validationErr.Pointer != test.Pointer {
+			t.Errorf("%v: expected pointer %v got %v", i, test.Pointer, validationErr.Pointer)
+		}
+	}
+}
+
+func TestOneOf_Errors(t *testing.T) {
+	// only one of the fields is an object, so its errors are reported
+	field := OneOf(String(), card)
+	err := field.Validate(map[string]interface{}{"number": "1234123412341234"})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Pointer != "/cvc" || validationErr.Rule != "required" {
+		t.Errorf("expected the missing cvc to be reported, got %v", err)
+	}
+
+	if err = field.Validate("card"); err != nil {
+		t.Error(err)
+	}
+
+	numbers := OneOf(Integer(), Number())
+	err = numbers.Validate(float64(1))
+	if !errors.As(err, &validationErr) || !reflect.DeepEqual(validationErr.Limit, []int{0, 1}) {
+		t.Errorf("expected the matching fields in the error, got %#v", err)
+	}
+}
+
+func TestOneOf_Strip(t *testing.T) {
+	field := OneOf(card, bank).Strip(true)
+
+	input := map[string]interface{}{"iban": "DE00", "extra": true}
+	if err := field.Validate(input); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(input, map[string]interface{}{"iban": "DE00"}) {
+		t.Errorf("expected the matching field to strip the input, got %v", input)
+	}
+}
+
+func TestAllOf(t *testing.T) {
+	named := Object(map[string]Field{"name": String().Required()})
+	aged := Object(map[string]Field{"age": Integer().Min(0).Default(1)})
+	field := AllOf(named, aged).Unknown(false)
+
+	input := map[string]interface{}{"name": "bob"}
+	if err := field.Validate(input); err != nil {
+		t.Fatal(err)
+	}
+	if input["age"] != 1 {
+		t.Errorf("expected defaults of all fields, got %v", input)
+	}
+	if err := field.Validate(map[string]interface{}{"age": float64(1)}); !errors.Is(err, ErrRequired) {
+		t.Errorf("expected %v got %v", ErrRequired, err)
+	}
+	if err := field.Validate(map[string]interface{}{"name": "bob", "age": float64(-1)}); !errors.Is(err, ErrMinimum) {
+		t.Errorf("expected %v got %v", ErrMinimum, err)
+	}
+	err := field.Validate(map[string]interface{}{"name": "bob", "other": 1})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Pointer != "/other" || !errors.Is(err, ErrUnknown) {
+		t.Errorf("expected an unknown error for other, got %v", err)
+	}
+
+	input = map[string]interface{}{"name": "bob", "age": float64(2), "other": 1}
+	stripped := AllOf(named, aged).Strip(true)
+	if err = stripped.Validate(input); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(input, map[string]interface{}{"name": "bob", "age": float64(2)}) {
+		t.Errorf("expected only unknown properties of all fields to be stripped, got %v", input)
+	}
+}
+
+func TestAnyOfNot(t *testing.T) {
+	field := AnyOf(String().Pattern("^a"), String().Pattern("b$"))
+	for input, expected := range map[string]error{"ab": nil, "ax": nil, "xb": nil, "xx": ErrAnyOf} {
+		if err := field.Validate(input); !errors.Is(err, expected) {
+			t.Errorf("%v: expected %v got %v", input, expected, err)
+		}
+	}
+
+	field = AllOf(String(), Not(String().Enum("admin", "root")))
+	if err := field.Validate("bob"); err != nil {
+		t.Error(err)
+	}
+	if err := field.Validate("root"); !errors.Is(err, ErrNot) {
+		t.Errorf("expected %v got %v", ErrNot, err)
+	}
+}
+
+func TestComposite_Schema(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+	err := r.Add(Spec{
+		Method: "POST",
+		Path:   "/payments",
+		Validate: Validate{Body: Object(map[string]Field{
+			"source": OneOf(Model("Card", card), Model("Bank", bank)),
+			"name":   AllOf(String(), Not(String().Enum("root"))),
+		})},
+		Responses: map[string]Response{
+			"200": {Description: "OK", Schema: JsonSchema{AnyOf: []JsonSchema{{Type: KindString}, {Type: KindInteger}}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref := r.Swagger.Paths["/payments"].Post.Parameters[0].Schema.Ref[len("#/definitions/"):]
+	source := r.Swagger.Definitions[ref].Properties["source"]
+	if source.Type != "" || len(source.OneOf) != 2 || source.OneOf[0].Ref != "#/definitions/Card" {
+		t.Errorf("unexpected schema %#v", source)
+	}
+	name := r.Swagger.Definitions[ref].Properties["name"]
+	if len(name.AllOf) != 2 || name.AllOf[1].Not == nil || name.AllOf[1].Not.Type != KindString {
+		t.Errorf("unexpected schema %#v", name)
+	}
+	// Swagger 2.0 has no oneOf, anyOf or not, so they are extensions there
+	swagger, _ := json.Marshal(r.Swagger)
+	for _, keyword := range []string{`"oneOf"`, `"anyOf"`, `"not"`} {
+		if strings.Contains(string(swagger), keyword) {
+			t.Errorf("expected no %v in %s", keyword, swagger)
+		}
+	}
+	if !strings.Contains(string(swagger), `"x-oneOf"`) || !strings.Contains(string(swagger), `"x-not"`) {
+		t.Errorf("expected the extensions in %s", swagger)
+	}
+	single := OneOf(String())
+	if schema := single.toJsonSchema(nil); len(schema.AllOf) != 1 || schema.OneOf != nil {
+		t.Errorf("expected one of a single field to be allOf, got %#v", schema)
+	}
+
+	openapi := r.OpenAPI.Components.Schemas[ref].Properties["source"]
+	if len(openapi.OneOf) != 2 || openapi.OneOf[1].Ref != "#/components/schemas/Bank" || openapi.Type != nil {
+		t.Errorf("unexpected schema %#v", openapi)
+	}
+
+	spec := &Spec{Responses: r.Swagger.Paths["/payments"].Post.Responses}
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	if err = r.CheckResponse(spec, 200, header, []byte(`1`)); err != nil {
+		t.Error(err)
+	}
+	if err = r.CheckResponse(spec, 200, header, []byte(`true`)); !errors.Is(err, ErrAnyOf) {
+		t.Errorf("expected %v got %v", ErrAnyOf, err)
+	}
+}
+
+func TestDiscriminated(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+	event := Discriminated("type", map[string]Field{
+		"click":  Model("Click", Object(map[string]Field{"x": Integer().Required(), "y": Integer().Required()})),
+		"scroll": Object(map[string]Field{"type": String(), "offset": Number().Min(0)}),
+	}).Unknown(false)
+
+	tests := []struct {
+		Input    string
+		Expected error
+		Pointer  string
+	}{
+		{Input: `{"type":"click","x":1,"y":2}`},
+		{Input: `{"type":"scroll","offset":10}`},
+		{Input: `{"type":"click","x":1}`, Expected: ErrRequired, Pointer: "/y"},
+		{Input: `{"type":"scroll","offset":-1}`, Expected: ErrMinimum, Pointer: "/offset"},
+		{Input: `{"type":"scroll","x":1}`, Expected: ErrUnknown, Pointer: "/x"},
+		{Input: `{"type":"drag"}`, Expected: ErrEnumNotFound, Pointer: "/type"},
+		{Input: `{"type":1}`, Expected: ErrEnumNotFound, Pointer: "/type"},
+		{Input: `{"x":1}`, Expected: ErrRequired, Pointer: "/type"},
+		{Input: `[]`, Expected: ErrWrongType, Pointer: ""},
+	}
+	for i, test := range tests {
+		var body interface{}
+		_ = json.Unmarshal([]byte(test.Input), &body)
+		err := r.Validate(Validate{Body: event}, nil, body, nil)
+		if !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", i, test.Expected, err)
+		}
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) && validationErr.Pointer != test.Pointer {
+			t.Errorf("%v: expected pointer %v got %v", i, test.Pointer, validationErr.Pointer)
+		}
+	}
+
+	err := r.Add(Spec{Method: "POST", Path: "/events", Validate: Validate{Body: Array().Items(event)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	click := r.Swagger.Definitions["Click"]
+	if click.Properties["type"].Type != KindString || !reflect.DeepEqual(click.Required, []string{"type", "x", "y"}) {
+		t.Errorf("expected the discriminator to be added to the variants, got %#v", click)
+	}
+	ref := r.Swagger.Paths["/events"].Post.Parameters[0].Schema.Ref[len("#/definitions/"):]
+	items := r.Swagger.Definitions[ref].Items
+	if items.Ref == "" {
+		t.Fatalf("expected a reference to the base definition, got %#v", items)
+	}
+	name := items.Ref[len("#/definitions/"):]
+	if base := r.Swagger.Definitions[name]; base.Discriminator != "type" || !reflect.DeepEqual(base.Required, []string{"type"}) {
+		t.Errorf("unexpected base definition %#v", base)
+	}
+	// each variant extends the base and documents the value choosing it, also when it has the property already
+	for _, value := range []string{"click", "scroll"} {
+		definition := r.Swagger.Definitions[name+exported(value)]
+		if definition.XDiscriminatorValue != value || len(definition.AllOf) != 2 || definition.AllOf[0].Ref != items.Ref {
+			t.Fatalf("expected %v to extend the base definition, got %#v", value, definition)
+		}
+		variant := definition.AllOf[1]
+		if variant.Ref != "" {
+			variant = r.Swagger.Definitions[variant.Ref[len("#/definitions/"):]]
+		}
+		if property := variant.Properties["type"]; !reflect.DeepEqual(property.Enum, []interface{}{value}) || !slices.Contains(variant.Required, "type") {
+			t.Errorf("expected the discriminator of %v to be documented, got %#v", value, variant)
+		}
+	}
+
+	// a named field is the base definition
+	err = r.Add(Spec{Method: "POST", Path: "/event", Validate: Validate{Body: Model("Event", event)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Swagger.Definitions["Event"].Discriminator != "type" || r.Swagger.Definitions["EventClick"].AllOf[0].Ref != "#/definitions/Event" {
+		t.Errorf("unexpected definitions %#v", r.Swagger.Definitions)
+	}
+	data, err := json.Marshal(r.Swagger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"discriminator":"type"`) || strings.Contains(string(data), "x-discriminator\"") {
+		t.Errorf("expected the discriminator keyword, got %s", data)
+	}
+	if scroll := r.OpenAPI.Components.Schemas[ref].Items.OneOf[1]; !reflect.DeepEqual(scroll.Properties["type"].Enum, []interface{}{"scroll"}) {
+		t.Errorf("expected the discriminator of scroll to be documented, got %#v", scroll)
+	}
+	discriminator := r.OpenAPI.Components.Schemas[ref].Items.Discriminator
+	expected := &Discriminator{PropertyName: "type", Mapping: map[string]string{"click": "#/components/schemas/Click"}}
+	if !reflect.DeepEqual(discriminator, expected) {
+		t.Errorf("expected %#v got %#v", expected, discriminator)
+	}
+}
@@ -0,0 +1,278 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// These errors are wrapped by ValidationError, use errors.Is to check for them.
+var (
+	ErrRequired     = fmt.Errorf("value is required")
+	ErrWrongType    = fmt.Errorf("wrong type passed")
+	ErrMaximum      = fmt.Errorf("maximum exceeded")
+	ErrMinimum      = fmt.Errorf("minimum exceeded")
+	ErrEnumNotFound = fmt.Errorf("value not in enum")
+	ErrUnknown      = fmt.Errorf("unknown value")
+	ErrPattern      = fmt.Errorf("value does not match pattern")
+	ErrMultipleOf   = fmt.Errorf("value is not a multiple")
+	ErrUniqueItems  = fmt.Errorf("items are not unique")
+	ErrFormat       = fmt.Errorf("value does not match format")
+	ErrFileType     = fmt.Errorf("file type not allowed")
+	ErrUndocumented = fmt.Errorf("response status not documented")
+	ErrOneOf        = fmt.Errorf("value must match exactly one schema")
+	ErrAnyOf        = fmt.Errorf("value must match at least one schema")
+	ErrNot          = fmt.Errorf("value must not match schema")
+	ErrCustom       = fmt.Errorf("custom validation failed")
+	// ErrOneRequired and ErrMutuallyExclusive are about the properties of an object, see OneRequired
+	ErrOneRequired       = fmt.Errorf("exactly one of the properties is required")
+	ErrMutuallyExclusive = fmt.Errorf("properties are mutually exclusive")
+	// These are the limits of JSON bodies, checked before they are decoded. See option.MaxBodyBytes.
+	ErrBodyTooLarge  = fmt.Errorf("body is too large")
+	ErrTooDeep       = fmt.Errorf("value is nested too deeply")
+	ErrTooManyValues = fmt.Errorf("body has too many values")
+	ErrStringTooLong = fmt.Errorf("string is too long")
+	// These are rejected by option.StrictJSON.
+	ErrDuplicateKey = fmt.Errorf("duplicate key")
+	ErrTrailingData = fmt.Errorf("unexpected data after the body")
+	ErrInvalidUTF8  = fmt.Errorf("string is not valid UTF-8")
+)
+
+// The parts of a request a ValidationError can be located in.
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+	InCookie = "cookie"
+	InBody   = "body"
+	InForm   = "form"
+	// InResponse is used when checking responses, see option.ValidateResponses.
+	InResponse = "response"
+)
+
+// ValidationError is returned when an input fails validation. It carries enough detail for
+// clients to point at the offending input without parsing the message.
+type ValidationError struct {
+	// In is where the value came from: path, query, header, body, form or response.
+	In string `json:"in"`
+	// Pointer is the JSON pointer (RFC 6901) to the value, e.g. /items/0/name. For path, query,
+	// header and form values it is the name of the parameter, e.g. /limit.
+	Pointer string `json:"pointer"`
+	// Rule is the name of the rule that failed, e.g. required, maximum, pattern.
+	Rule string `json:"rule"`
+	// Limit is the constraint the rule checked against, like the maximum or the allowed enum values.
+	Limit interface{} `json:"limit,omitempty"`
+	// Value is the value that was rejected.
+	Value interface{} `json:"value,omitempty"`
+	// Err is the underlying error, usually one of the Err variables in this package.
+	Err error `json:"-"`
+}
+
+func (e *ValidationError) Error() string {
+	if e.Pointer == "" {
+		return fmt.Sprintf("%v validation failed: %v", e.In, e.Err)
+	}
+	field := e.Pointer
+	if e.In != InBody {
+		field = strings.TrimPrefix(field, "/")
+	}
+	return fmt.Sprintf("%v validation failed for field %v: %v", e.In, field, e.Err)
+}
+
+// MarshalJSON adds the error message to the JSON so the error can be shown as is.
+func (e *ValidationError) MarshalJSON() ([]byte, error) {
+	type plain ValidationError
+	return json.Marshal(struct {
+		*plain
+		Message string `json:"message"`
+	}{(*plain)(e), e.Error()})
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors is returned instead of a single ValidationError when the router or spec
+// is set to collect errors, see option.CollectErrors.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// collector gathers validation errors until it holds limit of them. A limit below 1
+// means only the first error is kept, which is the default.
+type collector struct {
+	limit int
+	errs  ValidationErrors
+	// changed is set when stripping or defaulting changed the input
+	changed bool
+}
+
+// add records err and returns the collected errors once the limit is reached, which
+// tells the caller to stop validating. Otherwise it returns nil so validation continues.
+func (c *collector) add(err error) error {
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		// not something we know how to collect
+		return err
+	}
+	c.errs = append(c.errs, validationErr)
+	if len(c.errs) >= c.limit {
+		return c.err()
+	}
+	return nil
+}
+
+// err returns the collected errors, a single ValidationError when collecting is off.
+func (c *collector) err() error {
+	if len(c.errs) == 0 {
+		return nil
+	}
+	if c.limit <= 1 {
+		return c.errs[0]
+	}
+	return c.errs
+}
+
+// newValidationError wraps err in a ValidationError, working out the rule and limit from the field.
+// If err already is a ValidationError it is returned as is.
+func newValidationError(in, pointer string, field *Field, value interface{}, err error) error {
+	var existing *ValidationError
+	if errors.As(err, &existing) {
+		return err
+	}
+
+	e := &ValidationError{In: in, Pointer: pointer, Value: value, Err: err}
+	switch {
+	case errors.Is(err, ErrCustom):
+		// checked first, the error of a custom check could wrap any of the others
+		e.Rule = "custom"
+	case errors.Is(err, ErrRequired):
+		e.Rule = "required"
+	case errors.Is(err, ErrWrongType):
+		e.Rule = "type"
+		e.Limit = field.kind
+	case errors.Is(err, ErrMaximum):
+		e.Rule = "maximum"
+		if field.exclusiveMax {
+			e.Rule = "exclusiveMaximum"
+		}
+		if field.max != nil {
+			e.Limit = *field.max
+		}
+	case errors.Is(err, ErrMinimum):
+		e.Rule = "minimum"
+		if field.exclusiveMin {
+			e.Rule = "exclusiveMinimum"
+		}
+		if field.min != nil {
+			e.Limit = *field.min
+		}
+	case errors.Is(err, ErrMultipleOf):
+		e.Rule = "multipleOf"
+		if field.multipleOf != nil {
+			e.Limit = *field.multipleOf
+		}
+	case errors.Is(err, ErrUniqueItems):
+		e.Rule = "uniqueItems"
+	case errors.Is(err, ErrEnumNotFound):
+		e.Rule = "enum"
+		e.Limit = []interface{}(field.enum)
+	case errors.Is(err, ErrUnknown):
+		e.Rule = "unknown"
+	case errors.Is(err, ErrPattern):
+		e.Rule = "pattern"
+		if field.pattern != nil {
+			e.Limit = field.pattern.String()
+		}
+	case errors.Is(err, ErrOneOf):
+		e.Rule = "oneOf"
+	case errors.Is(err, ErrAnyOf):
+		e.Rule = "anyOf"
+	case errors.Is(err, ErrNot):
+		e.Rule = "not"
+	case errors.Is(err, ErrFileType):
+		e.Rule = "mimeTypes"
+		e.Limit = field.mimeTypes
+	case field.format != "":
+		e.Rule = "format"
+		e.Limit = field.format
+	default:
+		e.Rule = "invalid"
+	}
+	return e
+}
+
+// pointerEscaper escapes JSON pointer reference tokens per RFC 6901.
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// joinPointer appends a reference token to a JSON pointer.
+func joinPointer(pointer string, token interface{}) string {
+	var s string
+	switch t := token.(type) {
+	case string:
+		s = t
+	case int:
+		s = strconv.Itoa(t)
+	default:
+		s = fmt.Sprint(t)
+	}
+	return pointer + "/" + pointerEscaper.Replace(s)
+}
+
+// location is where a value is in a body, a property or item of its parent, or the body itself
+// when nil. Its JSON pointer is only built when an error is reported, not for every valid value.
+type location struct {
+	parent *location
+	key    string
+	// index is -1 for properties
+	index int
+}
+
+// property returns the location of a property of the object at l.
+func (l *location) property(key string) *location {
+	return &location{parent: l, key: key, index: -1}
+}
+
+// item returns the location of an item of the array at l.
+func (l *location) item(index int) *location {
+	return &location{parent: l, index: index}
+}
+
+// pointer returns the JSON pointer of the location.
+func (l *location) pointer() string {
+	depth := 0
+	for at := l; at != nil; at = at.parent {
+		depth++
+	}
+	// only the parents are linked, so each token is found by walking up from l
+	var pointer strings.Builder
+	for ; depth > 0; depth-- {
+		at := l
+		for range depth - 1 {
+			at = at.parent
+		}
+		pointer.WriteByte('/')
+		if at.index >= 0 {
+			pointer.WriteString(strconv.Itoa(at.index))
+		} else {
+			pointer.WriteString(strings.ReplaceAll(strings.ReplaceAll(at.key, "~", "~0"), "/", "~1"))
+		}
+	}
+	return pointer.String()
+}
@@ -0,0 +1,158 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/jakecoffman/crud/option"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestValidationError(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+
+	body := Object(map[string]Field{
+		"name": String().Max(3),
+		"items": Array().Items(Object(map[string]Field{
+			"id":   Integer().Required(),
+			"kind": String().Enum("a", "b"),
+		})),
+		"a/b": Boolean(),
+	})
+	query := Object(map[string]Field{
+		"limit": Integer().Max(25),
+	})
+
+	tests := []struct {
+		Validate Validate
+		Body     string
+		Query    string
+		Expected ValidationError
+	}{
+		{
+			Validate: Validate{Body: body},
+			Body:     `{"name":"toolong"}`,
+			Expected: ValidationError{In: InBody, Pointer: "/name", Rule: "maximum", Limit: 3., Value: "toolong", Err: ErrMaximum},
+		},
+		{
+			Validate: Validate{Body: body},
+			Body:     `{"items":[{"id":1},{}]}`,
+			Expected: ValidationError{In: InBody, Pointer: "/items/1/id", Rule: "required", Err: ErrRequired},
+		},
+		{
+			Validate: Validate{Body: body},
+			Body:     `{"items":[{"id":1,"kind":"c"}]}`,
+			Expected: ValidationError{In: InBody, Pointer: "/items/0/kind", Rule: "enum", Limit: []interface{}{"a", "b"}, Value: "c", Err: ErrEnumNotFound},
+		},
+		{
+			Validate: Validate{Body: body},
+			Body:     `{"a/b":"yes"}`,
+			Expected: ValidationError{In: InBody, Pointer: "/a~1b", Rule: "type", Limit: KindBoolean, Value: "yes", Err: ErrWrongType},
+		},
+		{
+			Validate: Validate{Body: body.Unknown(false)},
+			Body:     `{"extra":1}`,
+			Expected: ValidationError{In: InBody, Pointer: "/extra", Rule: "unknown", Value: 1., Err: ErrUnknown},
+		},
+		{
+			Validate: Validate{Query: query},
+			Query:    "limit=30",
+			Expected: ValidationError{In: InQuery, Pointer: "/limit", Rule: "maximum", Limit: 25., Value: "30", Err: ErrMaximum},
+		},
+	}
+
+	for i, test := range tests {
+		var input interface{}
+		if test.Body != "" {
+			if err := json.Unmarshal([]byte(test.Body), &input); err != nil {
+				t.Fatal(err)
+			}
+		}
+		values, _ := url.ParseQuery(test.Query)
+
+		err := r.ValidateInputs(test.Validate, &Inputs{Query: values, Body: input})
+
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%v: expected a ValidationError, got %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(*validationErr, test.Expected) {
+			t.Errorf("%v: expected %#v got %#v", i, test.Expected, *validationErr)
+		}
+		if !errors.Is(err, test.Expected.Err) {
+			t.Errorf("%v: expected errors.Is to find %v", i, test.Expected.Err)
+		}
+	}
+}
+
+func TestValidationError_Error(t *testing.T) {
+	err := &ValidationError{In: InPath, Pointer: "/id", Err: ErrMaximum}
+	if err.Error() != "path validation failed for field id: maximum exceeded" {
+		t.Error(err.Error())
+	}
+	err = &ValidationError{In: InBody, Pointer: "/items/0/id", Err: ErrRequired}
+	if err.Error() != "body validation failed for field /items/0/id: value is required" {
+		t.Error(err.Error())
+	}
+	err = &ValidationError{In: InBody, Err: ErrRequired}
+	if err.Error() != "body validation failed: value is required" {
+		t.Error(err.Error())
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{}, option.CollectErrors(10))
+
+	val := Validate{
+		Path: Object(map[string]Field{
+			"id": Integer().Max(5),
+		}),
+		Query: Object(map[string]Field{
+			"limit": Integer().Required(),
+		}),
+		Header: Object(map[string]Field{
+			"X-Token": String().Required(),
+		}),
+		Body: Object(map[string]Field{
+			"name": String().Required(),
+			"items": Array().Items(Object(map[string]Field{
+				"id": Integer().Required(),
+			})),
+		}),
+	}
+	var body interface{}
+	_ = json.Unmarshal([]byte(`{"items":[{},{"id":1},{}]}`), &body)
+	in := &Inputs{Path: map[string]string{"id": "6"}, Query: url.Values{}, Body: body}
+
+	err := r.ValidateInputs(val, in)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors got %v", err)
+	}
+	var pointers []string
+	for _, e := range errs {
+		pointers = append(pointers, e.In+":"+e.Pointer)
+	}
+	expected := []string{"path:/id", "query:/limit", "header:/X-Token", "body:/items/0/id", "body:/items/2/id", "body:/name"}
+	if !reflect.DeepEqual(pointers, expected) {
+		t.Errorf("expected %v got %v", expected, pointers)
+	}
+	if !errors.Is(err, ErrRequired) || !errors.Is(err, ErrMaximum) {
+		t.Errorf("expected errors.Is to find the sentinels in %v", err)
+	}
+
+	// the limit stops validation early
+	err = r.ValidateInputs(val, in, option.CollectErrors(2))
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Errorf("expected 2 errors, got %v", err)
+	}
+
+	// turning it off returns the first error only
+	err = r.ValidateInputs(val, in, option.CollectErrors(1))
+	var single *ValidationError
+	if !errors.As(err, &single) || errors.As(err, &errs) || single.Pointer != "/id" {
+		t.Errorf("expected a single error, got %v", err)
+	}
+}
@@ -1,17 +1,21 @@
 package crud
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"reflect"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Field allows specification of swagger or json schema types using the builder pattern.
 type Field struct {
 	kind        string
+	name        string
 	format      string
 	obj         map[string]Field
 	max         *float64
@@ -26,6 +30,31 @@ type Field struct {
 	allow       enum
 	strip       *bool
 	unknown     *bool
+	maxSize     *int64
+	mimeTypes   []string
+	maxFiles    *int
+	nullable    bool
+	of          []Field
+	// discriminator is the property choosing from variants, see Discriminated
+	discriminator string
+	variants      map[string]Field
+	// values and keyPattern are set on maps, see Map
+	values     *Field
+	keyPattern *regexp.Regexp
+	// custom holds the checks added with Custom
+	custom []func(value interface{}) error
+	// dependentRequired, oneRequired and exclusive are rules about the properties of objects,
+	// requiredIf is checked by the object holding the field, see rules.go
+	dependentRequired map[string][]string
+	oneRequired       [][]string
+	exclusive         [][]string
+	requiredIf        *condition
+	// exclusiveMin and exclusiveMax make min and max exclusive, see ExclusiveMin
+	exclusiveMin, exclusiveMax bool
+	multipleOf                 *float64
+	uniqueItems                bool
+	// names are the properties of obj in order, set by resolve so validation doesn't sort them
+	names []string
 }
 
 func (f Field) String() string {
@@ -53,188 +82,295 @@ type enum []interface{}
 
 func (e enum) has(needle interface{}) bool {
 	for _, value := range e {
-		if value == needle {
+		if value == needle || jsonEqual(value, needle) {
 			return true
 		}
 	}
 	return false
 }
 
-var (
-	errRequired     = fmt.Errorf("value is required")
-	errWrongType    = fmt.Errorf("wrong type passed")
-	errMaximum      = fmt.Errorf("maximum exceeded")
-	errMinimum      = fmt.Errorf("minimum exceeded")
-	errEnumNotFound = fmt.Errorf("value not in enum")
-	errUnknown      = fmt.Errorf("unknown value")
-	errPattern      = fmt.Errorf("value does not match pattern")
-)
-
 // Validate is used in the validation middleware to tell if the value passed
 // into the controller meets the restrictions set on the field.
 func (f *Field) Validate(value interface{}) error {
+	if value == nil && f.nullable {
+		return nil
+	}
 	if value == nil && f.required != nil && *f.required {
-		return errRequired
+		return ErrRequired
 	}
 	if value == nil {
 		return nil
 	}
+	if len(f.allow) > 0 && f.allow.has(value) {
+		return nil
+	}
+	if len(f.of) > 0 {
+		c := &collector{}
+		if err := validateComposite(nil, f, value, c); err != nil {
+			return err
+		}
+		if err := c.err(); err != nil {
+			return err
+		}
+		return f.validateCustom(value)
+	}
 
 	switch v := value.(type) {
 	case int:
 		if f.kind != KindInteger {
-			return errWrongType
-		}
-		if f.max != nil && float64(v) > *f.max {
-			return errMaximum
+			return ErrWrongType
 		}
-		if f.min != nil && float64(v) < *f.min {
-			return errMinimum
+		if err := f.validateInteger(int64(v)); err != nil {
+			return err
 		}
 	case float64:
 		if f.kind == KindInteger {
-			// since JSON is unmarshalled as float64 always
+			// JSON decoded without UseNumber has float64 for integers too
 			if float64(int(v)) != v {
-				return errWrongType
+				return ErrWrongType
+			}
+			if err := f.validateInteger(int64(v)); err != nil {
+				return err
 			}
 		} else if f.kind != KindNumber {
-			return errWrongType
-		}
-		if f.max != nil && v > *f.max {
-			return errMaximum
+			return ErrWrongType
+		} else if err := f.validateNumber(v); err != nil {
+			return err
 		}
-		if f.min != nil && v < *f.min {
-			return errMinimum
+	case json.Number:
+		if err := f.validateJSONNumber(v); err != nil {
+			return err
 		}
 	case string:
 		if f.kind != KindString {
-			return errWrongType
+			return ErrWrongType
 		}
-		if f.required != nil && *f.required && v == "" && !f.allow.has("") {
-			return errRequired
+		if f.required != nil && *f.required && v == "" {
+			return ErrRequired
 		}
-		if f.max != nil && len(v) > int(*f.max) {
-			return errMaximum
+		// lengths are counted in code points like JSON Schema does, not bytes
+		if f.max != nil && utf8.RuneCountInString(v) > int(*f.max) {
+			return ErrMaximum
 		}
-		if f.min != nil && len(v) < int(*f.min) {
-			return errMinimum
+		if f.min != nil && utf8.RuneCountInString(v) < int(*f.min) {
+			return ErrMinimum
 		}
 		if f.pattern != nil && !f.pattern.MatchString(v) {
-			return errPattern
+			return ErrPattern
 		}
-		switch f.format {
-		case FormatDateTime:
-			_, err := time.Parse(time.RFC3339, v)
-			if err != nil {
-				return err
-			}
-		case FormatDate:
-			_, err := time.Parse(fullDate, v)
-			if err != nil {
+		if validate, ok := lookupFormat(f.format); ok {
+			if err := validate(v); err != nil {
 				return err
 			}
 		}
 	case bool:
 		if f.kind != KindBoolean {
-			return errWrongType
+			return ErrWrongType
 		}
 	case []interface{}:
 		if f.kind != KindArray {
-			return errWrongType
+			return ErrWrongType
 		}
 		if f.min != nil && float64(len(v)) < *f.min {
-			return errMinimum
+			return ErrMinimum
 		}
 		if f.max != nil && float64(len(v)) > *f.max {
-			return errMaximum
+			return ErrMaximum
+		}
+		if f.uniqueItems && !uniqueItems(v) {
+			return ErrUniqueItems
 		}
 		if f.arr != nil {
-			// child fields inherit parent's settings, unless specified on child
-			if f.arr.strip == nil {
-				f.arr.strip = f.strip
-			}
-			if f.arr.unknown == nil {
-				f.arr.unknown = f.unknown
-			}
-			for _, item := range v {
-				if err := f.arr.Validate(item); err != nil {
+			// child fields inherit parent's settings, unless specified on child. The items are
+			// shared by every copy of the field, so they get a copy of their own to change.
+			item := f.arr.inherit(f)
+			for _, value := range v {
+				if err := item.Validate(value); err != nil {
 					return err
 				}
 			}
 		}
 	case map[string]interface{}:
 		if f.kind != KindObject {
-			return errWrongType
+			return ErrWrongType
+		}
+		c := &collector{}
+		if err := validateObject(nil, f, v, c); err != nil {
+			return err
 		}
-		return validateObject("", f, v)
+		return c.err()
 	default:
 		return fmt.Errorf("unhandled type %v", v)
 	}
 
 	if f.enum != nil && !f.enum.has(value) {
-		return errEnumNotFound
+		return ErrEnumNotFound
 	}
 
+	return f.validateCustom(value)
+}
+
+// validateNumber checks the bounds of a number and that it is a multiple of multipleOf.
+func (f *Field) validateNumber(v float64) error {
+	if f.max != nil && (v > *f.max || f.exclusiveMax && v == *f.max) {
+		return ErrMaximum
+	}
+	if f.min != nil && (v < *f.min || f.exclusiveMin && v == *f.min) {
+		return ErrMinimum
+	}
+	if f.multipleOf != nil && !isMultipleOf(decimal(v), *f.multipleOf) {
+		return ErrMultipleOf
+	}
+	return nil
+}
+
+// uniqueItems returns true if no two items of the array are the same JSON.
+func uniqueItems(items []interface{}) bool {
+	seen := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		// encoding/json sorts the keys of maps, so equal objects are encoded the same
+		data, err := json.Marshal(canonicalNumbers(item))
+		if err != nil {
+			return false
+		}
+		if _, ok := seen[string(data)]; ok {
+			return false
+		}
+		seen[string(data)] = struct{}{}
+	}
+	return true
+}
+
+// validateCustom runs the checks added with Custom, wrapping their errors with ErrCustom.
+func (f *Field) validateCustom(value interface{}) error {
+	for _, check := range f.custom {
+		if err := check(value); err != nil {
+			return fmt.Errorf("%w: %w", ErrCustom, err)
+		}
+	}
 	return nil
 }
 
 // validateObject is a recursive function that validates the field values in the object. It also
 // performs stripping of values, or erroring when unexpected fields are present, depending on the
-// options on the fields.
-func validateObject(name string, field *Field, input interface{}) error {
+// options on the fields. The location of the input in the body is used for reporting errors.
+// Errors are added to the collector, a non-nil error is returned once it is full.
+func validateObject(loc *location, field *Field, input interface{}, c *collector) error {
+	// custom checks of arrays, objects and combined fields only run once what they hold is valid
+	errs := len(c.errs)
+	custom := func() error {
+		if len(c.errs) > errs {
+			return nil
+		}
+		if err := field.validateCustom(input); err != nil {
+			return c.add(newValidationError(InBody, loc.pointer(), field, input, err))
+		}
+		return nil
+	}
+
+	if len(field.of) > 0 && input != nil {
+		if err := validateComposite(loc, field, input, c); err != nil {
+			return err
+		}
+		return custom()
+	}
+
 	switch v := input.(type) {
 	case nil:
+		// an explicit null, missing properties are handled by the parent
+		if field.nullable {
+			return nil
+		}
 		if field.required != nil && *field.required {
-			return fmt.Errorf("object validation failed for field %v: %w", name, errRequired)
+			return c.add(newValidationError(InBody, loc.pointer(), field, v, ErrRequired))
 		}
-	case string, bool:
+		return c.add(newValidationError(InBody, loc.pointer(), field, v, ErrWrongType))
+	case string, bool, int:
 		if err := field.Validate(v); err != nil {
-			return fmt.Errorf("object validation failed for field %v: %w", name, err)
+			return c.add(newValidationError(InBody, loc.pointer(), field, v, err))
 		}
 	case float64:
 		if field.kind == KindInteger {
 			// JSON doesn't have integers, so Go treats these fields as float64.
 			// Need to convert to integer before validating it.
 			if v != float64(int64(v)) {
-				return fmt.Errorf("object validation failed for field %v: %w", name, errWrongType)
+				return c.add(newValidationError(InBody, loc.pointer(), field, v, ErrWrongType))
 			}
 			if err := field.Validate(int(v)); err != nil {
-				return fmt.Errorf("object validation failed for field %v: %w", name, err)
+				return c.add(newValidationError(InBody, loc.pointer(), field, v, err))
 			}
 		} else {
 			if err := field.Validate(v); err != nil {
-				return fmt.Errorf("object validation failed for field %v: %w", name, err)
+				return c.add(newValidationError(InBody, loc.pointer(), field, v, err))
+			}
+		}
+	case json.Number:
+		// bodies are decoded with UseNumber, so integers past 2^53 aren't rounded. Validate gets
+		// an int or float64 when it can hold the number exactly, like without UseNumber.
+		var value interface{} = v
+		switch field.kind {
+		case KindInteger:
+			if n, err := strconv.ParseInt(string(v), 10, 64); err == nil && int64(int(n)) == n {
+				value = int(n)
 			}
+		case KindNumber:
+			if n, err := v.Float64(); err == nil {
+				value = n
+			}
+		}
+		if err := field.Validate(value); err != nil {
+			return c.add(newValidationError(InBody, loc.pointer(), field, v, err))
 		}
 	case []interface{}:
-		if err := field.Validate(v); err != nil {
-			return fmt.Errorf("object validation failed for field %v: %w", name, err)
+		// items are validated below so errors can point at them
+		array := *field
+		array.arr = nil
+		array.custom = nil
+		if err := array.Validate(v); err != nil {
+			return c.add(newValidationError(InBody, loc.pointer(), field, v, err))
 		}
 		if field.arr != nil {
-			for i, item := range v {
-				if err := validateObject(fmt.Sprintf("%v[%v]", name, i), field.arr, item); err != nil {
+			// child fields inherit parent's settings, unless specified on child
+			item := *field.arr
+			if item.strip == nil {
+				item.strip = field.strip
+			}
+			if item.unknown == nil {
+				item.unknown = field.unknown
+			}
+			for i, value := range v {
+				if err := validateObject(loc.item(i), &item, value, c); err != nil {
 					return err
 				}
 			}
 		}
+		return custom()
 	case map[string]interface{}:
-		if !field.isAllowUnknown() {
-			for key := range v {
-				if _, ok := field.obj[key]; !ok {
-					return fmt.Errorf("unknown field in object: %v %w", key, errUnknown)
-				}
+		if field.kind != KindObject {
+			return c.add(newValidationError(InBody, loc.pointer(), field, v, ErrWrongType))
+		}
+		if field.min != nil && float64(len(v)) < *field.min {
+			if err := c.add(newValidationError(InBody, loc.pointer(), field, v, ErrMinimum)); err != nil {
+				return err
+			}
+		}
+		if field.max != nil && float64(len(v)) > *field.max {
+			if err := c.add(newValidationError(InBody, loc.pointer(), field, v, ErrMaximum)); err != nil {
+				return err
 			}
 		}
 
-		if field.isStripUnknown() {
-			for key := range v {
-				if _, ok := field.obj[key]; !ok {
-					delete(v, key)
-				}
+		if field.values != nil {
+			// every key is known to a map
+			if err := validateMap(loc, field, v, c); err != nil {
+				return err
 			}
+		} else if err := checkUnknown(loc, field, v, field.obj, c); err != nil {
+			return err
 		}
 
-		for childName, childField := range field.obj {
+		for _, childName := range field.properties() {
+			childField := field.obj[childName]
 			// child fields inherit parent's settings, unless specified on child
 			if childField.strip == nil {
 				childField.strip = field.strip
@@ -243,17 +379,34 @@ func validateObject(name string, field *Field, input interface{}) error {
 				childField.unknown = field.unknown
 			}
 
-			newV := v[childName]
-			if newV == nil && childField.required != nil && *childField.required {
-				return fmt.Errorf("object validation failed for field %v.%v: %w", name, childName, errRequired)
-			} else if newV == nil && childField._default != nil {
-				v[childName] = childField._default
-			} else if err := validateObject(name+"."+childName, &childField, v[childName]); err != nil {
-				return err
+			newV, present := v[childName]
+			if !present && childField.required != nil && *childField.required {
+				if err := c.add(newValidationError(InBody, loc.property(childName).pointer(), &childField, nil, ErrRequired)); err != nil {
+					return err
+				}
+			} else if !present && childField._default != nil {
+				// a copy, so requests don't share the default
+				v[childName] = copyJSON(childField._default)
+				c.changed = true
+				switch v[childName].(type) {
+				case map[string]interface{}, []interface{}:
+					// which gets the defaults of its own properties
+					if err := validateObject(loc.property(childName), &childField, v[childName], c); err != nil {
+						return err
+					}
+				}
+			} else if present {
+				if err := validateObject(loc.property(childName), &childField, newV, c); err != nil {
+					return err
+				}
 			}
 		}
+		if err := validateObjectRules(loc, field, v, c); err != nil {
+			return err
+		}
+		return custom()
 	default:
-		return fmt.Errorf("object validation failed for type %v: %w", reflect.TypeOf(v), errWrongType)
+		return c.add(newValidationError(InBody, loc.pointer(), field, v, ErrWrongType))
 	}
 	return nil
 }
@@ -267,6 +420,12 @@ const (
 	KindArray   = "array"
 	KindFile    = "file"
 	KindInteger = "integer"
+
+	// These kinds combine other fields, see OneOf, AnyOf, AllOf and Not.
+	KindOneOf = "oneOf"
+	KindAnyOf = "anyOf"
+	KindAllOf = "allOf"
+	KindNot   = "not"
 )
 
 // Number creates a field with floating point type
@@ -320,6 +479,7 @@ func Integer() Field {
 // Min specifies a minimum value for this field
 func (f Field) Min(min float64) Field {
 	f.min = &min
+	f.exclusiveMin = false
 	if f.max != nil && *f.max < min {
 		panic("min cannot be larger than max")
 	}
@@ -329,12 +489,72 @@ func (f Field) Min(min float64) Field {
 // Max specifies a maximum value for this field
 func (f Field) Max(max float64) Field {
 	f.max = &max
+	f.exclusiveMax = false
 	if f.min != nil && *f.min > max {
 		panic("min cannot be larger than max")
 	}
 	return f
 }
 
+// ExclusiveMin specifies a minimum the value of a number or integer must be greater than
+func (f Field) ExclusiveMin(min float64) Field {
+	if f.kind != KindNumber && f.kind != KindInteger {
+		panic("ExclusiveMin can only be used with numbers")
+	}
+	f = f.Min(min)
+	f.exclusiveMin = true
+	return f
+}
+
+// ExclusiveMax specifies a maximum the value of a number or integer must be less than
+func (f Field) ExclusiveMax(max float64) Field {
+	if f.kind != KindNumber && f.kind != KindInteger {
+		panic("ExclusiveMax can only be used with numbers")
+	}
+	f = f.Max(max)
+	f.exclusiveMax = true
+	return f
+}
+
+// MultipleOf specifies the value of a number or integer must be a multiple of n. Decimals are
+// compared as written, so 0.3 is a multiple of 0.01 despite floating point.
+func (f Field) MultipleOf(n float64) Field {
+	if f.kind != KindNumber && f.kind != KindInteger {
+		panic("MultipleOf can only be used with numbers")
+	}
+	if n <= 0 {
+		panic("MultipleOf must be greater than 0")
+	}
+	f.multipleOf = &n
+	return f
+}
+
+// UniqueItems specifies the items of an array must all be different. Objects and arrays
+// are compared by their content.
+func (f Field) UniqueItems() Field {
+	if f.kind != KindArray {
+		panic("UniqueItems can only be used with arrays")
+	}
+	f.uniqueItems = true
+	return f
+}
+
+// MinProperties specifies the minimum number of properties of an object, it is the same as Min.
+func (f Field) MinProperties(min int) Field {
+	if f.kind != KindObject {
+		panic("MinProperties can only be used with objects")
+	}
+	return f.Min(float64(min))
+}
+
+// MaxProperties specifies the maximum number of properties of an object, it is the same as Max.
+func (f Field) MaxProperties(max int) Field {
+	if f.kind != KindObject {
+		panic("MaxProperties can only be used with objects")
+	}
+	return f.Max(float64(max))
+}
+
 // Pattern specifies a regex pattern value for this field
 func (f Field) Pattern(pattern string) Field {
 	f.pattern = regexp.MustCompile(pattern)
@@ -352,6 +572,25 @@ func (f Field) Required() Field {
 	return f
 }
 
+// Custom adds a check for rules only this field has. It runs after the other rules passed, for arrays
+// and objects after their items and properties did. The value is what was decoded, e.g. a
+// map[string]interface{} for objects and an int for integers, or a json.Number for integers an int
+// can't hold. Errors are wrapped with ErrCustom and reported with the custom rule and the path of the field.
+func (f Field) Custom(check func(value interface{}) error) Field {
+	if check == nil {
+		panic("Custom needs a check")
+	}
+	f.custom = append(slices.Clip(f.custom), check)
+	return f
+}
+
+// Nullable allows the field to be an explicit null, which is otherwise rejected. A missing property is
+// still checked by Required and gets the Default, so a PATCH can tell clearing a value from leaving it alone.
+func (f Field) Nullable() Field {
+	f.nullable = true
+	return f
+}
+
 // Example specifies an example value for the swagger to display
 func (f Field) Example(ex interface{}) Field {
 	f.example = ex
@@ -364,31 +603,62 @@ func (f Field) Description(description string) Field {
 	return f
 }
 
-// Default specifies a default value to use if the field is nil. Can't be used with Required.
+// Default specifies a default value to use if the field is missing. Can't be used with Required.
+// Objects and arrays can be given as anything encoding/json encodes as one, like a map or a struct.
+// Each request gets a copy of them, and the defaults of their own properties are applied too.
 func (f Field) Default(value interface{}) Field {
 	if f.required != nil && *f.required {
 		panic("default and required cannot be used together")
 	}
 
+	// combined fields take whatever the fields they combine take
+	is := func(kind string) bool {
+		return f.kind == kind || len(f.of) > 0
+	}
 	switch value.(type) {
 	case int:
-		if f.kind != KindInteger {
+		if !is(KindInteger) {
 			panic("wrong type passed default")
 		}
 	case float64:
-		if f.kind != KindNumber {
+		if !is(KindNumber) {
 			panic("wrong type passed default")
 		}
 	case string:
-		if f.kind != KindString {
+		if !is(KindString) {
 			panic("wrong type passed default")
 		}
 	case bool:
-		if f.kind != KindBoolean {
+		if !is(KindBoolean) {
 			panic("wrong type passed default")
 		}
 	default:
-		panic("default must be an int, float64, bool or string")
+		// kept as decoded JSON so it can be copied with copyJSON
+		data, err := json.Marshal(value)
+		if err != nil {
+			panic(fmt.Sprintf("default can't be encoded: %v", err))
+		}
+		value = nil
+		// with UseNumber, so large integers aren't rounded
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		_ = decoder.Decode(&value)
+		switch v := value.(type) {
+		case json.Number:
+			if !is(KindNumber) && !(is(KindInteger) && integral(v)) {
+				panic("wrong type passed default")
+			}
+		case map[string]interface{}:
+			if !is(KindObject) {
+				panic("wrong type passed default")
+			}
+		case []interface{}:
+			if !is(KindArray) {
+				panic("wrong type passed default")
+			}
+		default:
+			panic("default must be an int, float64, bool, string, object or array")
+		}
 	}
 	f._default = value
 	return f
@@ -412,6 +682,34 @@ func (f Field) Items(item Field) Field {
 	return f
 }
 
+// MaxSize limits the size in bytes of each file uploaded to a File field
+func (f Field) MaxSize(bytes int64) Field {
+	if f.kind != KindFile {
+		panic("MaxSize can only be used with file types")
+	}
+	f.maxSize = &bytes
+	return f
+}
+
+// MimeTypes restricts the files uploaded to a File field to the types given, e.g. "image/png" or "image/*".
+// The type is detected from the content of the file rather than trusting what the client sent.
+func (f Field) MimeTypes(types ...string) Field {
+	if f.kind != KindFile {
+		panic("MimeTypes can only be used with file types")
+	}
+	f.mimeTypes = append(f.mimeTypes, types...)
+	return f
+}
+
+// MaxFiles sets how many files can be uploaded to a File field. Defaults to 1.
+func (f Field) MaxFiles(max int) Field {
+	if f.kind != KindFile {
+		panic("MaxFiles can only be used with file types")
+	}
+	f.maxFiles = &max
+	return f
+}
+
 const (
 	FormatDate     = "date"
 	FormatDateTime = "dateTime"
@@ -419,12 +717,13 @@ const (
 
 // Format is used to set custom format types. Note that formats with special
 // validation in this library also have their own constructor. See DateTime for example.
+// Other formats are only documented unless they are registered with RegisterFormat.
 func (f Field) Format(format string) Field {
 	f.format = format
 	return f
 }
 
-// Allow lets you break rules
+// Allow lets you break rules, the values are valid whatever the other rules of the field say
 // For example, String().Required() excludes "", unless you Allow("")
 func (f Field) Allow(values ...interface{}) Field {
 	f.allow = append(f.allow, values...)
@@ -454,6 +753,9 @@ func (f *Field) ToSwaggerParameters(in string) (parameters []Parameter) {
 			Required:         f.required,
 			Description:      f.description,
 			Default:          f._default,
+			MinItems:         intLimit(f.min),
+			MaxItems:         intLimit(f.max),
+			UniqueItems:      f.uniqueItems,
 		}
 		if f.arr != nil {
 			items := f.arr.ToJsonSchema()
@@ -470,8 +772,22 @@ func (f *Field) ToSwaggerParameters(in string) (parameters []Parameter) {
 				Description: field.description,
 				Default:     field._default,
 				Enum:        field.enum,
-				Minimum:     field.min,
-				Maximum:     field.max,
+				MultipleOf:  field.multipleOf,
+				UniqueItems: field.uniqueItems,
+
+				ExclusiveMinimum: field.exclusiveMin,
+				ExclusiveMaximum: field.exclusiveMax,
+			}
+			switch field.kind {
+			case KindString:
+				param.MinLength = intLimit(field.min)
+				param.MaxLength = intLimit(field.max)
+			case KindArray:
+				param.MinItems = intLimit(field.min)
+				param.MaxItems = intLimit(field.max)
+			default:
+				param.Minimum = field.min
+				param.Maximum = field.max
 			}
 			if field.pattern != nil {
 				param.Pattern = field.pattern.String()
@@ -501,65 +817,138 @@ func (f *Field) ToSwaggerParameters(in string) (parameters []Parameter) {
 // ToJsonSchema transforms a field into a Swagger Schema.
 // TODO this is an extension of JsonSchema, rename in v2 ToSchema() Schema
 func (f *Field) ToJsonSchema() JsonSchema {
+	return f.toJsonSchema(nil)
+}
+
+// toJsonSchema transforms a field into a Swagger Schema. Models are added to defs and referenced,
+// or inlined when defs is nil.
+func (f *Field) toJsonSchema(defs *definitions) JsonSchema {
+	if f.name != "" && defs != nil {
+		return defs.ref(f)
+	}
+
 	schema := JsonSchema{
-		Type: f.kind,
+		Type:        f.kind,
+		Format:      f.format,
+		Example:     f.exampleValue(),
+		Description: f.describe(),
+		Default:     f._default,
+		Enum:        f.enum,
+		XNullable:   f.nullable,
+	}
+	switch f.kind {
+	case KindString:
+		schema.MinLength = intLimit(f.min)
+		schema.MaxLength = intLimit(f.max)
+	case KindArray:
+		schema.MinItems = intLimit(f.min)
+		schema.MaxItems = intLimit(f.max)
+	case KindObject:
+		schema.MinProperties = intLimit(f.min)
+		schema.MaxProperties = intLimit(f.max)
+	default:
+		schema.Minimum = f.min
+		schema.Maximum = f.max
+	}
+	if f.pattern != nil {
+		schema.Pattern = f.pattern.String()
 	}
+	if f.multipleOf != nil {
+		schema.MultipleOf = *f.multipleOf
+	}
+	schema.ExclusiveMinimum = f.exclusiveMin
+	schema.ExclusiveMaximum = f.exclusiveMax
+	schema.UniqueItems = f.uniqueItems
 
 	switch f.kind {
 	case KindArray:
 		if f.arr != nil {
-			items := f.arr.ToJsonSchema()
+			items := f.arr.toJsonSchema(defs)
 			schema.Items = &items
 		}
 	case KindObject:
-		populateProperties(f.obj, &schema)
+		populateProperties(f.obj, &schema, defs)
+		if f.values != nil {
+			values := f.values.toJsonSchema(defs)
+			schema.AdditionalProperties = &values
+		}
+	case KindOneOf, KindAnyOf, KindAllOf, KindNot:
+		if f.discriminator != "" && defs != nil {
+			return defs.discriminated("", f)
+		}
+		// combined fields have the types of what they combine
+		schema.Type = ""
+		var of []JsonSchema
+		for i := range f.of {
+			of = append(of, f.of[i].toJsonSchema(defs))
+		}
+		switch {
+		case f.kind == KindAllOf, len(of) == 1 && f.kind != KindNot:
+			// matching one of a single field is matching all of them, which Swagger 2.0 has
+			schema.AllOf = of
+		case f.kind == KindOneOf:
+			schema.OneOf = of
+		case f.kind == KindAnyOf:
+			schema.AnyOf = of
+		case f.kind == KindNot:
+			schema.Not = &of[0]
+		}
+		if f.discriminator != "" {
+			// without definitions to extend, the variants are only listed, see definitions.discriminated
+			schema.Type = KindObject
+			schema.Properties = map[string]JsonSchema{f.discriminator: {Type: KindString, Enum: f.discriminatorValues()}}
+			schema.Required = []string{f.discriminator}
+			schema.Discriminator = f.discriminator
+		}
 	}
 	return schema
 }
 
+// discriminatorValues returns the values of the discriminator property choosing the variants, in order.
+func (f *Field) discriminatorValues() []interface{} {
+	var values []interface{}
+	for _, value := range sortedKeys(f.variants) {
+		values = append(values, value)
+	}
+	return values
+}
+
 // recursively fill in the schema
-func populateProperties(obj map[string]Field, schema *JsonSchema) {
+func populateProperties(obj map[string]Field, schema *JsonSchema, defs *definitions) {
 	schema.Properties = map[string]JsonSchema{}
-	for name, field := range obj {
-		prop := JsonSchema{
-			Type:        field.kind,
-			Format:      field.format,
-			Example:     field.example,
-			Description: field.description,
-			Default:     field._default,
-		}
-		if field.example == nil {
-			if field.kind == KindString {
-				switch field.format {
-				case FormatDateTime:
-					prop.Example = time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local).Format(time.RFC3339)
-				case FormatDate:
-					prop.Example = time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local).Format(fullDate)
-				}
-			}
-		}
+	for _, name := range sortedKeys(obj) {
+		field := obj[name]
 		if field.required != nil && *field.required {
 			schema.Required = append(schema.Required, name)
 		}
-		if field.min != nil {
-			prop.Minimum = *field.min
-		}
-		if field.max != nil {
-			prop.Maximum = *field.max
-		}
-		if field.pattern != nil {
-			prop.Pattern = field.pattern.String()
-		}
-		if prop.Type == KindArray {
-			if field.arr != nil {
-				items := field.arr.ToJsonSchema()
-				prop.Items = &items
-			}
-		} else if prop.Type == KindObject {
-			populateProperties(field.obj, &prop)
-		}
-		schema.Properties[name] = prop
+		schema.Properties[name] = field.toJsonSchema(defs)
+	}
+}
+
+// intLimit converts a limit on a count for the schema.
+func intLimit(limit *float64) *int {
+	if limit == nil {
+		return nil
+	}
+	n := int(*limit)
+	return &n
+}
+
+// exampleValue returns the example of the field, or one made up from its format if it has none.
+func (f *Field) exampleValue() interface{} {
+	if f.example != nil || f.kind != KindString {
+		return f.example
+	}
+	switch f.format {
+	case FormatDateTime:
+		return time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local).Format(time.RFC3339)
+	case FormatDate:
+		return time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local).Format(fullDate)
+	}
+	if example, ok := formatExamples[f.format]; ok {
+		return example
 	}
+	return nil
 }
 
 func (f Field) isAllowUnknown() bool {
@@ -575,3 +964,21 @@ func (f Field) isStripUnknown() bool {
 	}
 	return *f.strip
 }
+
+// properties returns the names of the properties of the object in order.
+func (f *Field) properties() []string {
+	if f.names != nil {
+		return f.names
+	}
+	return sortedKeys(f.obj)
+}
+
+// sortedKeys returns the keys of m in order so validation visits fields deterministically.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -1,8 +1,11 @@
 package crud
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -51,17 +54,17 @@ func TestField_String(t *testing.T) {
 		{
 			Field:    String().Required(),
 			Input:    nil,
-			Expected: errRequired,
+			Expected: ErrRequired,
 		},
 		{
 			Field:    String().Required(),
 			Input:    7,
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Field:    String().Required(),
 			Input:    "",
-			Expected: errRequired,
+			Expected: ErrRequired,
 		},
 		{
 			Field:    String().Required().Allow(""),
@@ -76,7 +79,7 @@ func TestField_String(t *testing.T) {
 		{
 			Field:    String().Min(1),
 			Input:    "",
-			Expected: errMinimum,
+			Expected: ErrMinimum,
 		},
 		{
 			Field:    String().Min(1),
@@ -91,12 +94,12 @@ func TestField_String(t *testing.T) {
 		{
 			Field:    String().Max(1),
 			Input:    "12",
-			Expected: errMaximum,
+			Expected: ErrMaximum,
 		},
 		{
 			Field:    String().Enum("hi"),
 			Input:    "",
-			Expected: errEnumNotFound,
+			Expected: ErrEnumNotFound,
 		},
 		{
 			Field:    String().Enum("hi"),
@@ -111,7 +114,7 @@ func TestField_String(t *testing.T) {
 		{
 			Field:    String().Pattern("[a-c]"),
 			Input:    "def",
-			Expected: errPattern,
+			Expected: ErrPattern,
 		},
 	}
 
@@ -131,12 +134,12 @@ func TestField_Integer(t *testing.T) {
 		{
 			Field:    Integer(),
 			Input:    7.2,
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Field:    Integer(),
 			Input:    "7",
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Field:    Integer(),
@@ -151,7 +154,7 @@ func TestField_Integer(t *testing.T) {
 		{
 			Field:    Integer().Required(),
 			Input:    nil,
-			Expected: errRequired,
+			Expected: ErrRequired,
 		},
 		{
 			Field:    Integer().Required(),
@@ -161,7 +164,7 @@ func TestField_Integer(t *testing.T) {
 		{
 			Field:    Integer().Min(1),
 			Input:    0,
-			Expected: errMinimum,
+			Expected: ErrMinimum,
 		},
 		{
 			Field:    Integer().Min(1),
@@ -176,12 +179,12 @@ func TestField_Integer(t *testing.T) {
 		{
 			Field:    Integer().Max(1),
 			Input:    12,
-			Expected: errMaximum,
+			Expected: ErrMaximum,
 		},
 		{
 			Field:    Integer().Enum(1, 2, 3),
 			Input:    4,
-			Expected: errEnumNotFound,
+			Expected: ErrEnumNotFound,
 		},
 		{
 			Field:    Integer().Enum(1, 2, 3),
@@ -206,7 +209,7 @@ func TestField_Float64(t *testing.T) {
 		{
 			Field:    Number(),
 			Input:    "7",
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Field:    Number(),
@@ -216,7 +219,7 @@ func TestField_Float64(t *testing.T) {
 		{
 			Field:    Number().Required(),
 			Input:    nil,
-			Expected: errRequired,
+			Expected: ErrRequired,
 		},
 		{
 			Field:    Number().Required(),
@@ -226,7 +229,7 @@ func TestField_Float64(t *testing.T) {
 		{
 			Field:    Number().Min(1.1),
 			Input:    0.,
-			Expected: errMinimum,
+			Expected: ErrMinimum,
 		},
 		{
 			Field:    Number().Min(1.1),
@@ -241,12 +244,12 @@ func TestField_Float64(t *testing.T) {
 		{
 			Field:    Number().Max(1),
 			Input:    12.,
-			Expected: errMaximum,
+			Expected: ErrMaximum,
 		},
 		{
 			Field:    Number().Enum(1., 2.),
 			Input:    3.,
-			Expected: errEnumNotFound,
+			Expected: ErrEnumNotFound,
 		},
 		{
 			Field:    Number().Enum(1., 2.),
@@ -271,7 +274,7 @@ func TestField_Boolean(t *testing.T) {
 		{
 			Field:    Boolean(),
 			Input:    "true",
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Field:    Boolean(),
@@ -281,7 +284,7 @@ func TestField_Boolean(t *testing.T) {
 		{
 			Field:    Boolean().Required(),
 			Input:    nil,
-			Expected: errRequired,
+			Expected: ErrRequired,
 		},
 		{
 			Field:    Boolean().Required(),
@@ -296,7 +299,7 @@ func TestField_Boolean(t *testing.T) {
 		{
 			Field:    Boolean().Enum(true),
 			Input:    false,
-			Expected: errEnumNotFound,
+			Expected: ErrEnumNotFound,
 		},
 		{
 			Field:    Boolean().Enum(true),
@@ -321,7 +324,7 @@ func TestField_Array(t *testing.T) {
 		{
 			Field:    Array(),
 			Input:    true,
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Field:    Array(),
@@ -331,7 +334,7 @@ func TestField_Array(t *testing.T) {
 		{
 			Field:    Array().Required(),
 			Input:    nil,
-			Expected: errRequired,
+			Expected: ErrRequired,
 		},
 		{
 			Field:    Array().Required(),
@@ -346,7 +349,7 @@ func TestField_Array(t *testing.T) {
 		{
 			Field:    Array().Min(1),
 			Input:    []interface{}{},
-			Expected: errMinimum,
+			Expected: ErrMinimum,
 		},
 		{
 			Field:    Array().Min(1),
@@ -356,7 +359,7 @@ func TestField_Array(t *testing.T) {
 		{
 			Field:    Array().Max(1),
 			Input:    []interface{}{1, 2},
-			Expected: errMaximum,
+			Expected: ErrMaximum,
 		},
 		{
 			Field:    Array().Max(1),
@@ -371,7 +374,7 @@ func TestField_Array(t *testing.T) {
 		{
 			Field:    Array().Items(String().Required()),
 			Input:    []interface{}{""},
-			Expected: errRequired,
+			Expected: ErrRequired,
 		},
 		{
 			Field:    Array().Items(String().Required()),
@@ -396,7 +399,7 @@ func TestField_Object(t *testing.T) {
 		{
 			Field:    Object(map[string]Field{}),
 			Input:    "",
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Field:    Object(map[string]Field{}),
@@ -406,7 +409,7 @@ func TestField_Object(t *testing.T) {
 		{
 			Field:    Object(map[string]Field{}).Required(),
 			Input:    nil,
-			Expected: errRequired,
+			Expected: ErrRequired,
 		},
 		{
 			Field:    Object(map[string]Field{}).Required(),
@@ -418,7 +421,7 @@ func TestField_Object(t *testing.T) {
 				"nested": Integer().Required().Min(1),
 			}),
 			Input:    map[string]interface{}{},
-			Expected: errRequired,
+			Expected: ErrRequired,
 		},
 		{
 			Field: Object(map[string]Field{
@@ -427,7 +430,7 @@ func TestField_Object(t *testing.T) {
 			Input: map[string]interface{}{
 				"nested": 1.1,
 			},
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Field: Object(map[string]Field{
@@ -587,3 +590,290 @@ func TestToSwaggerParameters(t *testing.T) {
 		}
 	})
 }
+
+func TestField_Nullable(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+	patch := Object(map[string]Field{
+		"name":     String().Required(),
+		"nickname": String().Required().Nullable(),
+		"bio":      String().Nullable().Default("none"),
+		"age":      Integer().Default(1),
+		"tags":     Array().Items(String()),
+	})
+
+	tests := []struct {
+		Input    string
+		Expected error
+		Output   string
+	}{
+		{Input: `{"name":"bob","nickname":null}`, Output: `{"age":1,"bio":"none","name":"bob","nickname":null}`},
+		{Input: `{"name":"bob","nickname":"b","bio":null}`, Output: `{"age":1,"bio":null,"name":"bob","nickname":"b"}`},
+		{Input: `{"name":"bob"}`, Expected: ErrRequired},
+		{Input: `{"name":null,"nickname":null}`, Expected: ErrRequired},
+		{Input: `{"name":"bob","nickname":null,"age":null}`, Expected: ErrWrongType},
+		{Input: `{"name":"bob","nickname":null,"tags":[null]}`, Expected: ErrWrongType},
+	}
+
+	for i, test := range tests {
+		var body interface{}
+		_ = json.Unmarshal([]byte(test.Input), &body)
+		err := r.Validate(Validate{Body: patch}, nil, body, nil)
+		if !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", i, test.Expected, err)
+		}
+		if output, _ := json.Marshal(body); test.Output != "" && string(output) != test.Output {
+			t.Errorf("%v: expected %v got %s", i, test.Output, output)
+		}
+	}
+
+	withManager := Object(map[string]Field{"manager": Model("Manager", Object(map[string]Field{})).Nullable()})
+	if err := r.Add(Spec{Method: "PATCH", Path: "/users", Validate: Validate{Body: Model("User", patch)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add(Spec{Method: "PATCH", Path: "/teams", Validate: Validate{Body: withManager}}); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Swagger.Definitions["User"].Properties["nickname"].XNullable || r.Swagger.Definitions["User"].Properties["name"].XNullable {
+		t.Errorf("expected x-nullable on nullable fields only")
+	}
+	nickname := r.OpenAPI.Components.Schemas["User"].Properties["nickname"]
+	if !reflect.DeepEqual(nickname.Type, SchemaType{KindString, "null"}) {
+		t.Errorf("expected a type array, got %v", nickname.Type)
+	}
+	if r.Swagger.Definitions["Manager"].XNullable {
+		t.Errorf("expected only the reference to a model to be nullable")
+	}
+	ref := r.Swagger.Paths["/teams"].Patch.Parameters[0].Schema.Ref[len("#/definitions/"):]
+	if manager := r.OpenAPI.Components.Schemas[ref].Properties["manager"]; len(manager.OneOf) != 2 || manager.OneOf[0].Ref != "#/components/schemas/Manager" {
+		t.Errorf("expected a nullable reference, got %#v", manager)
+	}
+}
+
+func TestField_Custom(t *testing.T) {
+	errOdd := errors.New("must be even")
+	even := func(value interface{}) error {
+		if value.(int)%2 != 0 {
+			return errOdd
+		}
+		return nil
+	}
+	var calls int
+	distinct := func(value interface{}) error {
+		calls++
+		m := value.(map[string]interface{})
+		if m["from"] == m["to"] {
+			return errors.New("from and to must differ")
+		}
+		return nil
+	}
+	body := Object(map[string]Field{
+		"count": Integer().Min(0).Custom(even),
+		"from":  String().Required(),
+		"to":    String().Required(),
+	}).Custom(distinct)
+
+	r := NewRouter("", "", &TestAdapter{})
+	tests := []struct {
+		Input    string
+		Pointer  string
+		Expected error
+	}{
+		{Input: `{"from":"a","to":"b","count":2}`},
+		{Input: `{"from":"a","to":"b","count":3}`, Pointer: "/count", Expected: errOdd},
+		{Input: `{"from":"a","to":"b","count":-1}`, Pointer: "/count", Expected: ErrMinimum},
+		{Input: `{"from":"a","to":"a"}`, Pointer: "", Expected: ErrCustom},
+		{Input: `{"from":"a"}`, Pointer: "/to", Expected: ErrRequired},
+	}
+	for i, test := range tests {
+		calls = 0
+		var input interface{}
+		_ = json.Unmarshal([]byte(test.Input), &input)
+		err := r.Validate(Validate{Body: body}, nil, input, nil)
+		if !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", i, test.Expected, err)
+			continue
+		}
+		var validationErr *ValidationError
+		if test.Expected != nil && errors.As(err, &validationErr) && validationErr.Pointer != test.Pointer {
+			t.Errorf("%v: expected pointer %q got %q", i, test.Pointer, validationErr.Pointer)
+		}
+		if errors.Is(err, ErrCustom) && validationErr.Rule != "custom" {
+			t.Errorf("%v: expected the custom rule, got %v", i, validationErr.Rule)
+		}
+		if test.Expected == ErrRequired && calls != 0 {
+			t.Errorf("%v: expected the object check to be skipped when a property is invalid", i)
+		}
+	}
+
+	query := url.Values{"count": []string{"3"}}
+	err := r.Validate(Validate{Query: Object(map[string]Field{"count": Integer().Custom(even)})}, query, nil, nil)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.In != InQuery || validationErr.Pointer != "/count" || !errors.Is(err, errOdd) {
+		t.Errorf("expected the custom error of the query parameter, got %v", err)
+	}
+}
+
+func TestField_NumericAndArrayConstraints(t *testing.T) {
+	tests := []struct {
+		Field    Field
+		Value    interface{}
+		Expected error
+	}{
+		{Number().ExclusiveMin(0), 0.0, ErrMinimum},
+		{Number().ExclusiveMin(0), 0.1, nil},
+		{Integer().ExclusiveMax(10), 10, ErrMaximum},
+		{Integer().ExclusiveMax(10), 9, nil},
+		{Number().ExclusiveMin(0).Min(0), 0.0, nil},
+		{Number().MultipleOf(0.01), 0.3, nil},
+		{Number().MultipleOf(0.01), 19.99, nil},
+		{Number().MultipleOf(0.01), 0.301, ErrMultipleOf},
+		{Integer().MultipleOf(5), 15, nil},
+		{Integer().MultipleOf(5), 16.0, ErrMultipleOf},
+		{Array().UniqueItems(), []interface{}{1.0, "1", true}, nil},
+		{Array().UniqueItems(), []interface{}{map[string]interface{}{"a": 1.0, "b": []interface{}{"x"}}, map[string]interface{}{"b": []interface{}{"x"}, "a": 1.0}}, ErrUniqueItems},
+		{Object(map[string]Field{}).MinProperties(1), map[string]interface{}{}, ErrMinimum},
+		{Object(map[string]Field{}).MaxProperties(1), map[string]interface{}{"a": 1.0, "b": 2.0}, ErrMaximum},
+		{Object(map[string]Field{}).MaxProperties(1), map[string]interface{}{"a": 1.0}, nil},
+	}
+	for i, test := range tests {
+		if err := test.Field.Validate(test.Value); !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", i, test.Expected, err)
+		}
+	}
+
+	price := Object(map[string]Field{"price": Number().ExclusiveMin(0).MultipleOf(0.01)})
+	err := price.Validate(map[string]interface{}{"price": 0.0})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Rule != "exclusiveMinimum" || validationErr.Limit != 0.0 {
+		t.Errorf("expected the exclusiveMinimum rule, got %v", err)
+	}
+
+	schema := price.ToJsonSchema().Properties["price"]
+	if !schema.ExclusiveMinimum || schema.MultipleOf != 0.01 {
+		t.Errorf("unexpected swagger schema %+v", schema)
+	}
+	openAPI := price.toSchema(nil).Properties["price"]
+	if openAPI.Minimum != nil || openAPI.ExclusiveMinimum == nil || *openAPI.ExclusiveMinimum != 0 || *openAPI.MultipleOf != 0.01 {
+		t.Errorf("unexpected openapi schema %+v", openAPI)
+	}
+	tags := Object(map[string]Field{"tag": Array().Items(String()).UniqueItems()})
+	if params := tags.ToSwaggerParameters(InQuery); !params[0].UniqueItems {
+		t.Errorf("expected uniqueItems on the parameter")
+	}
+
+	r := NewRouter("", "", &TestAdapter{})
+	query := url.Values{"tag": []string{"a", "a"}}
+	if err := r.Validate(Validate{Query: tags}, query, nil, nil); !errors.Is(err, ErrUniqueItems) {
+		t.Errorf("expected duplicate query values to be rejected, got %v", err)
+	}
+}
+
+func TestField_Lengths(t *testing.T) {
+	name := String().Min(2).Max(3)
+	if err := name.Validate("日本語"); err != nil {
+		t.Errorf("expected lengths to be counted in code points, got %v", err)
+	}
+	if err := name.Validate("héé!"); !errors.Is(err, ErrMaximum) {
+		t.Errorf("expected %v got %v", ErrMaximum, err)
+	}
+	if err := name.Validate("é"); !errors.Is(err, ErrMinimum) {
+		t.Errorf("expected %v got %v", ErrMinimum, err)
+	}
+
+	body := Object(map[string]Field{
+		"name": name,
+		"tags": Array().Items(String()).Min(1).Max(5),
+		"age":  Integer().Min(0).Max(150),
+	})
+	schema := body.ToJsonSchema()
+	if s := schema.Properties["name"]; *s.MinLength != 2 || *s.MaxLength != 3 || s.Minimum != nil || s.Maximum != nil {
+		t.Errorf("expected minLength and maxLength for strings, got %+v", s)
+	}
+	if s := schema.Properties["tags"]; *s.MinItems != 1 || *s.MaxItems != 5 || s.Minimum != nil || s.Maximum != nil {
+		t.Errorf("expected minItems and maxItems for arrays, got %+v", s)
+	}
+	if s := schema.Properties["age"]; *s.Maximum != 150 || *s.Minimum != 0 || s.MaxLength != nil {
+		t.Errorf("expected maximum for integers, got %+v", s)
+	}
+	if s := body.toSchema(nil).Properties["name"]; *s.MaxLength != 3 || s.Maximum != nil {
+		t.Errorf("expected maxLength in the openapi schema, got %+v", s)
+	}
+
+	params := body.ToSwaggerParameters(InQuery)
+	if p := params[1]; *p.MaxLength != 3 || p.Maximum != nil {
+		t.Errorf("expected maxLength on the name parameter, got %+v", p)
+	}
+	if p := params[2]; *p.MaxItems != 5 || p.Maximum != nil {
+		t.Errorf("expected maxItems on the tags parameter, got %+v", p)
+	}
+}
+
+func TestField_Default_JSON(t *testing.T) {
+	type point struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	body := Object(map[string]Field{
+		"origin": Object(map[string]Field{"x": Integer(), "y": Integer()}).Default(point{1, 2}),
+		"style": Object(map[string]Field{
+			"color": String().Default("red"),
+			"tags":  Array().Items(String()).Default([]string{"a"}),
+		}).Default(map[string]interface{}{}),
+		"lines": Array().Items(Object(map[string]Field{
+			"qty": Integer().Default(1),
+		})),
+	})
+
+	r := NewRouter("", "", &TestAdapter{})
+	var outputs []string
+	for i := 0; i < 2; i++ {
+		var input interface{}
+		_ = json.Unmarshal([]byte(`{"lines":[{},{"qty":3}]}`), &input)
+		if err := r.Validate(Validate{Body: body}, nil, input, nil); err != nil {
+			t.Fatal(err)
+		}
+		output, _ := json.Marshal(input)
+		outputs = append(outputs, string(output))
+		// changing what a request got must not change the defaults of the next one
+		input.(map[string]interface{})["origin"].(map[string]interface{})["x"] = 100.0
+		input.(map[string]interface{})["style"].(map[string]interface{})["tags"].([]interface{})[0] = "changed"
+	}
+	expected := `{"lines":[{"qty":1},{"qty":3}],"origin":{"x":1,"y":2},"style":{"color":"red","tags":["a"]}}`
+	for _, output := range outputs {
+		if output != expected {
+			t.Errorf("expected %v got %v", expected, output)
+		}
+	}
+
+	query := url.Values{}
+	spec := Object(map[string]Field{"sort": Array().Items(String()).Default([]string{"name", "id"})})
+	if err := r.Validate(Validate{Query: spec}, query, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(query["sort"], []string{"name", "id"}) {
+		t.Errorf("expected the default of the query array, got %v", query["sort"])
+	}
+
+	schema := body.ToJsonSchema()
+	if !reflect.DeepEqual(schema.Properties["origin"].Default, map[string]interface{}{"x": json.Number("1"), "y": json.Number("2")}) {
+		t.Errorf("expected the default in the schema, got %v", schema.Properties["origin"].Default)
+	}
+	if params := spec.ToSwaggerParameters(InQuery); !reflect.DeepEqual(params[0].Default, []interface{}{"name", "id"}) {
+		t.Errorf("expected the default on the parameter, got %v", params[0].Default)
+	}
+
+	for _, build := range []func(){
+		func() { Object(nil).Default([]string{"a"}) },
+		func() { Array().Default(map[string]string{}) },
+		func() { String().Default(nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic")
+				}
+			}()
+			build()
+		}()
+	}
+}
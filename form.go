@@ -0,0 +1,133 @@
+package crud
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultMaxMemory is the amount of multipart form data kept in memory while parsing,
+// the rest is stored in temporary files. It matches the net/http default.
+var DefaultMaxMemory int64 = 32 << 20
+
+// ParseForm reads an application/x-www-form-urlencoded or multipart/form-data body so it
+// can be validated. The values returned are the ones from r.PostForm and r.MultipartForm
+// so changes made during validation are seen by handlers after r.Form is reset.
+func ParseForm(r *http.Request) (url.Values, map[string][]*multipart.FileHeader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(DefaultMaxMemory); err != nil {
+			return nil, nil, err
+		}
+		return r.PostForm, r.MultipartForm.File, nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, nil, err
+	}
+	return r.PostForm, nil, nil
+}
+
+// validateFiles checks the uploaded files against the File fields in the form spec.
+func validateFiles(spec Field, files map[string][]*multipart.FileHeader, allowUnknown, stripUnknown bool, c *collector) error {
+	if !allowUnknown {
+		for _, key := range sortedKeys(files) {
+			if _, ok := spec.obj[key]; !ok {
+				if err := c.add(newValidationError(InForm, joinPointer("", key), &spec, nil, ErrUnknown)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if stripUnknown {
+		for key := range files {
+			if _, ok := spec.obj[key]; !ok {
+				delete(files, key)
+			}
+		}
+	}
+
+	for _, field := range sortedKeys(spec.obj) {
+		schema := spec.obj[field]
+		for _, err := range validateFileField(field, schema, files[field]) {
+			if err = c.add(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateFileField validates the files uploaded for a single field of validateFiles.
+func validateFileField(field string, schema Field, headers []*multipart.FileHeader) (errs []error) {
+	if schema.kind != KindFile {
+		if len(headers) > 0 {
+			errs = append(errs, newValidationError(InForm, joinPointer("", field), &schema, nil, ErrWrongType))
+		}
+		return
+	}
+
+	if len(headers) == 0 {
+		if schema.required != nil && *schema.required {
+			errs = append(errs, newValidationError(InForm, joinPointer("", field), &schema, nil, ErrRequired))
+		}
+		return
+	}
+
+	maxFiles := 1
+	if schema.maxFiles != nil {
+		maxFiles = *schema.maxFiles
+	}
+	if len(headers) > maxFiles {
+		errs = append(errs, &ValidationError{In: InForm, Pointer: joinPointer("", field), Rule: "maxFiles", Limit: maxFiles, Value: len(headers), Err: ErrMaximum})
+		return
+	}
+
+	for i, header := range headers {
+		pointer := joinPointer("", field)
+		if maxFiles > 1 {
+			pointer = joinPointer(pointer, i)
+		}
+		if err := schema.validateFile(pointer, header); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return
+}
+
+// validateFile checks the size and the sniffed content type of an uploaded file.
+func (f *Field) validateFile(pointer string, header *multipart.FileHeader) error {
+	if f.maxSize != nil && header.Size > *f.maxSize {
+		return &ValidationError{In: InForm, Pointer: pointer, Rule: "maxSize", Limit: *f.maxSize, Value: header.Size, Err: ErrMaximum}
+	}
+	if len(f.mimeTypes) == 0 {
+		return nil
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// DetectContentType considers at most the first 512 bytes
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+
+	for _, allowed := range f.mimeTypes {
+		if allowed == detected {
+			return nil
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(detected, prefix+"/") {
+			return nil
+		}
+	}
+	return &ValidationError{In: InForm, Pointer: pointer, Rule: "mimeTypes", Limit: f.mimeTypes, Value: detected, Err: ErrFileType}
+}
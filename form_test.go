@@ -0,0 +1,129 @@
+package crud
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n0000000000")
+
+func multipartRequest(t *testing.T, values map[string]string, files map[string][][]byte) *multipart.Form {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range values {
+		_ = mw.WriteField(k, v)
+	}
+	for k, contents := range files {
+		for _, content := range contents {
+			fw, err := mw.CreateFormFile(k, k+".bin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = fw.Write(content)
+		}
+	}
+	_ = mw.Close()
+
+	r := httptest.NewRequest("POST", "/", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if _, _, err := ParseForm(r); err != nil {
+		t.Fatal(err)
+	}
+	return r.MultipartForm
+}
+
+func TestFormValidation(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+
+	tests := []struct {
+		Schema   map[string]Field
+		Values   map[string]string
+		Files    map[string][][]byte
+		Expected error
+	}{
+		{
+			Schema:   map[string]Field{"name": String().Required()},
+			Expected: ErrRequired,
+		},
+		{
+			Schema:   map[string]Field{"name": String().Required(), "age": Integer().Min(0)},
+			Values:   map[string]string{"name": "bob", "age": "-1"},
+			Expected: ErrMinimum,
+		},
+		{
+			Schema:   map[string]Field{"avatar": File().Required()},
+			Expected: ErrRequired,
+		},
+		{
+			Schema:   map[string]Field{"avatar": File().Required()},
+			Files:    map[string][][]byte{"avatar": {pngHeader}},
+			Expected: nil,
+		},
+		{
+			Schema:   map[string]Field{"avatar": File().MaxSize(4)},
+			Files:    map[string][][]byte{"avatar": {pngHeader}},
+			Expected: ErrMaximum,
+		},
+		{
+			Schema:   map[string]Field{"avatar": File()},
+			Files:    map[string][][]byte{"avatar": {pngHeader, pngHeader}},
+			Expected: ErrMaximum,
+		},
+		{
+			Schema:   map[string]Field{"avatar": File().MaxFiles(2)},
+			Files:    map[string][][]byte{"avatar": {pngHeader, pngHeader}},
+			Expected: nil,
+		},
+		{
+			Schema:   map[string]Field{"avatar": File().MimeTypes("image/png")},
+			Files:    map[string][][]byte{"avatar": {pngHeader}},
+			Expected: nil,
+		},
+		{
+			Schema:   map[string]Field{"avatar": File().MimeTypes("image/*")},
+			Files:    map[string][][]byte{"avatar": {pngHeader}},
+			Expected: nil,
+		},
+		{
+			Schema:   map[string]Field{"avatar": File().MimeTypes("image/*")},
+			Files:    map[string][][]byte{"avatar": {[]byte("just some text")}},
+			Expected: ErrFileType,
+		},
+		{
+			Schema:   map[string]Field{"name": String()},
+			Files:    map[string][][]byte{"name": {[]byte("not a value")}},
+			Expected: ErrWrongType,
+		},
+	}
+
+	for i, test := range tests {
+		form := multipartRequest(t, test.Values, test.Files)
+
+		err := r.ValidateInputs(Validate{FormData: Object(test.Schema)}, &Inputs{Form: form.Value, Files: form.File})
+
+		if !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected '%v' got '%v'. schema: '%v'", i, test.Expected, err, test.Schema)
+		}
+	}
+}
+
+func TestFormStripAndDefaults(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+	form := url.Values{"unknown": []string{"value"}}
+
+	err := r.ValidateInputs(Validate{FormData: Object(map[string]Field{
+		"page": Integer().Default(1),
+	})}, &Inputs{Form: form})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if form.Encode() != "page=1" {
+		t.Errorf("unexpected form %v", form.Encode())
+	}
+}
@@ -0,0 +1,224 @@
+package crud
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The formats validated by this library, see the constructors of the same names.
+const (
+	FormatEmail    = "email"
+	FormatUUID     = "uuid"
+	FormatURI      = "uri"
+	FormatIPv4     = "ipv4"
+	FormatIPv6     = "ipv6"
+	FormatHostname = "hostname"
+	FormatByte     = "byte"
+	FormatDuration = "duration"
+	FormatInt64    = "int64"
+	FormatUint64   = "uint64"
+)
+
+// Email creates a string field holding an email address like user@example.com, without a display name.
+func Email() Field {
+	return Field{kind: KindString, format: FormatEmail}
+}
+
+// UUID creates a string field holding a UUID in its canonical form, e.g. 3fa85f64-5717-4562-b3fc-2c963f66afa6.
+func UUID() Field {
+	return Field{kind: KindString, format: FormatUUID}
+}
+
+// URI creates a string field holding an absolute URI, e.g. https://example.com/path.
+func URI() Field {
+	return Field{kind: KindString, format: FormatURI}
+}
+
+// IPv4 creates a string field holding an IPv4 address in dotted decimal form.
+func IPv4() Field {
+	return Field{kind: KindString, format: FormatIPv4}
+}
+
+// IPv6 creates a string field holding an IPv6 address, without a zone.
+func IPv6() Field {
+	return Field{kind: KindString, format: FormatIPv6}
+}
+
+// Hostname creates a string field holding a host name as defined by RFC 1123.
+func Hostname() Field {
+	return Field{kind: KindString, format: FormatHostname}
+}
+
+// Byte creates a string field holding base64 encoded data, the way encoding/json encodes []byte.
+func Byte() Field {
+	return Field{kind: KindString, format: FormatByte}
+}
+
+// Duration creates a string field holding an ISO 8601 duration, e.g. P3DT4H.
+func Duration() Field {
+	return Field{kind: KindString, format: FormatDuration}
+}
+
+// Int64 creates an integer field whose values must fit in an int64.
+func Int64() Field {
+	return Field{kind: KindInteger, format: FormatInt64}
+}
+
+// Uint64 creates an integer field whose values must fit in a uint64, so can't be negative.
+func Uint64() Field {
+	return Field{kind: KindInteger, format: FormatUint64}
+}
+
+// RegisterFormat makes Format(name) check strings with validate, for domain rules like SKU checksums
+// or currency codes. Errors returned by validate are wrapped with ErrFormat and reported with the
+// format rule. Registering a name again replaces its validator, built-in formats included.
+// RegisterFormat panics if the name is empty or validate is nil.
+func RegisterFormat(name string, validate func(string) error) {
+	if name == "" {
+		panic("format name must not be empty")
+	}
+	if validate == nil {
+		panic("format " + name + " needs a validator")
+	}
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	formats[name] = func(s string) error {
+		if err := validate(s); err != nil {
+			return fmt.Errorf("%w: %w", ErrFormat, err)
+		}
+		return nil
+	}
+}
+
+// lookupFormat returns the function checking the format, if it has one.
+func lookupFormat(name string) (func(string) error, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	validate, ok := formats[name]
+	return validate, ok
+}
+
+var formatsMu sync.RWMutex
+
+// formats maps the formats with special validation to the function checking them. Dates return
+// the *time.ParseError, all others wrap ErrFormat.
+var formats = map[string]func(string) error{
+	FormatDateTime: func(s string) error {
+		_, err := time.Parse(time.RFC3339, s)
+		return err
+	},
+	FormatDate: func(s string) error {
+		_, err := time.Parse(fullDate, s)
+		return err
+	},
+	FormatEmail: func(s string) error {
+		address, err := mail.ParseAddress(s)
+		if err != nil {
+			return formatError(err)
+		}
+		if address.Address != s {
+			return formatError("not a plain address")
+		}
+		return nil
+	},
+	FormatUUID: func(s string) error {
+		if !uuidPattern.MatchString(s) {
+			return formatError("not a uuid")
+		}
+		return nil
+	},
+	FormatURI: func(s string) error {
+		u, err := url.Parse(s)
+		if err != nil {
+			return formatError(err)
+		}
+		if !u.IsAbs() {
+			return formatError("not an absolute uri")
+		}
+		return nil
+	},
+	FormatIPv4: func(s string) error {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return formatError(err)
+		}
+		if !addr.Is4() {
+			return formatError("not an ipv4 address")
+		}
+		return nil
+	},
+	FormatIPv6: func(s string) error {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return formatError(err)
+		}
+		if !addr.Is6() || addr.Zone() != "" {
+			return formatError("not an ipv6 address")
+		}
+		return nil
+	},
+	FormatHostname: validateHostname,
+	FormatByte: func(s string) error {
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			return formatError(err)
+		}
+		return nil
+	},
+	FormatDuration: func(s string) error {
+		if !durationPattern.MatchString(s) || s == "P" || strings.HasSuffix(s, "T") {
+			return formatError("not an ISO 8601 duration")
+		}
+		return nil
+	},
+}
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	durationPattern = regexp.MustCompile(`^P(\d+Y)?(\d+M)?(\d+W)?(\d+D)?(T(\d+H)?(\d+M)?(\d+(\.\d+)?S)?)?$`)
+)
+
+// validateHostname checks the host name has at most 253 characters, in labels of 1 to 63 letters,
+// digits and hyphens that don't start or end with a hyphen.
+func validateHostname(s string) error {
+	if len(s) == 0 || len(s) > 253 {
+		return formatError("hostname must have 1 to 253 characters")
+	}
+	for _, label := range strings.Split(s, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return formatError("hostname labels must have 1 to 63 characters")
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return formatError("hostname labels must not start or end with a hyphen")
+		}
+		for _, r := range label {
+			if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-') {
+				return formatError(fmt.Sprintf("hostname contains %q", r))
+			}
+		}
+	}
+	return nil
+}
+
+// formatExamples are the examples documented for fields with a format and no example of their own.
+var formatExamples = map[string]string{
+	FormatEmail:    "user@example.com",
+	FormatUUID:     "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+	FormatURI:      "https://example.com",
+	FormatIPv4:     "192.0.2.1",
+	FormatIPv6:     "2001:db8::1",
+	FormatHostname: "example.com",
+	FormatByte:     "ZXhhbXBsZQ==",
+	FormatDuration: "P3DT4H",
+}
+
+// formatError wraps ErrFormat with the reason the value doesn't match.
+func formatError(reason interface{}) error {
+	return fmt.Errorf("%w: %v", ErrFormat, reason)
+}
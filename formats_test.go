@@ -0,0 +1,82 @@
+package crud
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		field   Field
+		valid   []string
+		invalid []string
+	}{
+		{Email(), []string{"user@example.com", "first.last+tag@sub.example.org"}, []string{"user", "User <user@example.com>", "@example.com"}},
+		{UUID(), []string{"3fa85f64-5717-4562-b3fc-2c963f66afa6", "3FA85F64-5717-4562-B3FC-2C963F66AFA6"}, []string{"3fa85f64571745626b3fc2c963f66afa6", "3fa85f64-5717-4562-b3fc-2c963f66afaz"}},
+		{URI(), []string{"https://example.com/path?q=1", "urn:isbn:0451450523"}, []string{"/relative/path", "http://[::1", ""}},
+		{IPv4(), []string{"192.0.2.1", "0.0.0.0"}, []string{"256.0.0.1", "2001:db8::1", "::ffff:192.0.2.1", "192.0.2"}},
+		{IPv6(), []string{"2001:db8::1", "::1", "::ffff:192.0.2.1"}, []string{"192.0.2.1", "fe80::1%eth0", "2001:db8:::1"}},
+		{Hostname(), []string{"example.com", "localhost", "a-b.c1"}, []string{"-example.com", "example-.com", "exa_mple.com", "example..com", ""}},
+		{Byte(), []string{"ZXhhbXBsZQ==", ""}, []string{"ZXhhbXBsZQ", "not base64!"}},
+		{Duration(), []string{"P3DT4H", "PT0.5S", "P1Y2M3W4D", "PT15M"}, []string{"P", "PT", "3 days", "P1H", "1h30m"}},
+	}
+	for _, test := range tests {
+		t.Run(test.field.format, func(t *testing.T) {
+			for _, value := range test.valid {
+				if err := test.field.Validate(value); err != nil {
+					t.Errorf("expected %q to be valid, got %v", value, err)
+				}
+			}
+			for _, value := range test.invalid {
+				if err := test.field.Validate(value); !errors.Is(err, ErrFormat) {
+					t.Errorf("expected %q to fail with ErrFormat, got %v", value, err)
+				}
+			}
+			example := test.field.ToJsonSchema().Example
+			if err := test.field.Validate(example); err != nil {
+				t.Errorf("expected the example %v to be valid, got %v", example, err)
+			}
+		})
+	}
+}
+
+func TestFormats_ValidationError(t *testing.T) {
+	field := Object(map[string]Field{"email": Email()})
+	err := field.Validate(map[string]interface{}{"email": "nope"})
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	if validationErr.Pointer != "/email" || validationErr.Rule != "format" || validationErr.Limit != FormatEmail {
+		t.Errorf("unexpected error %+v", validationErr)
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	errCurrency := errors.New("unknown currency")
+	RegisterFormat("currency", func(s string) error {
+		if s != "EUR" && s != "USD" {
+			return errCurrency
+		}
+		return nil
+	})
+	defer func() {
+		formatsMu.Lock()
+		delete(formats, "currency")
+		formatsMu.Unlock()
+	}()
+
+	field := Object(map[string]Field{"currency": String().Format("currency")})
+	if err := field.Validate(map[string]interface{}{"currency": "EUR"}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	err := field.Validate(map[string]interface{}{"currency": "XXX"})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !errors.Is(err, ErrFormat) || !errors.Is(err, errCurrency) {
+		t.Fatalf("expected a format error wrapping the registered one, got %v", err)
+	}
+	if validationErr.Pointer != "/currency" || validationErr.Rule != "format" || validationErr.Limit != "currency" {
+		t.Errorf("unexpected error %+v", validationErr)
+	}
+}
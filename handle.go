@@ -0,0 +1,497 @@
+package crud
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Handle creates a typed handler for a Spec. When the spec is added to the router the inputs are
+// described from Req and the response from Resp, unless the spec already sets them:
+//
+//   - Fields of Req tagged `path:"name"`, `query:"name"`, `header:"name"` or `cookie:"name"` become
+//     parameters in those locations. Path parameters are always required, the others only when tagged so.
+//     All other fields make up the JSON body. If Req isn't a struct, all of it is the body.
+//   - Resp is documented as the response of the lowest 2xx status in Spec.Responses, or 200.
+//
+// After validation Req is set from the validated inputs and the handler is called. What it returns is
+// encoded as JSON with the documented status. Return a StatusError to choose the status code of an error.
+// Errors are written by the ErrorRenderer of the spec.
+//
+// The handler is an http.Handler so it works with every adapter.
+func Handle[Req, Resp any](handler func(ctx context.Context, req Req) (Resp, error)) http.Handler {
+	return &typedHandler[Req, Resp]{
+		handler:  handler,
+		plan:     planRequest(reflect.TypeFor[Req]()),
+		status:   http.StatusOK,
+		renderer: ProblemRenderer{},
+	}
+}
+
+// StatusError is returned by typed handlers to respond with a status code other than 500.
+type StatusError struct {
+	Status int
+	Err    error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// describer is implemented by handlers that fill in the Spec from their types, see Handle. It returns
+// the handler to install for the spec, the describer itself is left as it was so it can be added again.
+type describer interface {
+	describe(r *Router, spec *Spec) http.Handler
+}
+
+type typedHandler[Req, Resp any] struct {
+	handler func(ctx context.Context, req Req) (Resp, error)
+	plan    *requestPlan
+	// status and renderer are set for the spec the handler is added with
+	status   int
+	renderer ErrorRenderer
+}
+
+// parameter locations that can be set with struct tags on Req
+var parameterTags = []string{InPath, InQuery, InHeader, InCookie}
+
+func (h *typedHandler[Req, Resp]) describe(r *Router, spec *Spec) http.Handler {
+	reqType := reflect.TypeFor[Req]()
+	params, body := requestFields(reqType)
+	for _, in := range parameterTags {
+		var f *Field
+		switch in {
+		case InPath:
+			f = &spec.Validate.Path
+		case InQuery:
+			f = &spec.Validate.Query
+		case InHeader:
+			f = &spec.Validate.Header
+		case InCookie:
+			f = &spec.Validate.Cookie
+		}
+		if !f.Initialized() && len(params[in]) > 0 {
+			*f = Object(params[in])
+		}
+	}
+	if !spec.Validate.Body.Initialized() && body.Initialized() {
+		spec.Validate.Body = body
+	}
+
+	bound := *h
+	bound.renderer = r.settings.apply(spec.Options...).errorRenderer
+	bound.status = describeResponse[Resp](spec)
+	return &bound
+}
+
+// describeResponse documents Resp as the response of the lowest 2xx status of the spec, or 200 if it
+// has none, and returns that status.
+func describeResponse[Resp any](spec *Spec) int {
+	status := http.StatusOK
+	respField := fieldOf(reflect.TypeFor[Resp]())
+	response := Response{Description: http.StatusText(status)}
+	if respField.Initialized() {
+		response.Schema = respField.ToJsonSchema()
+	}
+	var codes []int
+	for code := range spec.Responses {
+		if status, err := strconv.Atoi(code); err == nil && status >= 200 && status < 300 {
+			codes = append(codes, status)
+		}
+	}
+	if len(codes) == 0 {
+		responses := map[string]Response{"200": response}
+		for code, response := range spec.Responses {
+			responses[code] = response
+		}
+		spec.Responses = responses
+		return status
+	}
+
+	status = slices.Min(codes)
+	documented := spec.Responses[strconv.Itoa(status)]
+	if documented.Schema.Type == "" && response.Schema.Type != "" {
+		documented.Schema = response.Schema
+		responses := map[string]Response{}
+		for code, response := range spec.Responses {
+			responses[code] = response
+		}
+		responses[strconv.Itoa(status)] = documented
+		spec.Responses = responses
+	}
+	return status
+}
+
+// requestFields splits the fields of t into parameters by location and the body.
+func requestFields(t reflect.Type) (params map[string]map[string]Field, body Field) {
+	params = map[string]map[string]Field{}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return params, fieldOf(t)
+	}
+
+	seen := map[reflect.Type]bool{t: true}
+	obj := map[string]Field{}
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || isPromoted(sf) {
+			continue
+		}
+		if in, name, ok := parameterTag(sf); ok {
+			if params[in] == nil {
+				params[in] = map[string]Field{}
+			}
+			// missing parameters are common, so they are only required when tagged, except in the
+			// path where there is always a value
+			field := structField(sf, seen, false)
+			if in == InPath {
+				field = field.Required()
+			}
+			params[in][name] = field
+			continue
+		}
+		name, ok := jsonName(sf)
+		if !ok {
+			continue
+		}
+		if field := structField(sf, seen, true); field.Initialized() {
+			obj[name] = field
+		}
+	}
+	if len(obj) > 0 {
+		body = Object(obj)
+	}
+	return params, body
+}
+
+// parameterTag returns the location and name of a struct field tagged as a parameter.
+func parameterTag(sf reflect.StructField) (in, name string, ok bool) {
+	for _, in = range parameterTags {
+		if name, ok = sf.Tag.Lookup(in); ok {
+			return in, name, true
+		}
+	}
+	return "", "", false
+}
+
+func (h *typedHandler[Req, Resp]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Req
+	if err := h.plan.decode(r, reflect.ValueOf(&req).Elem()); err != nil {
+		h.renderer.RenderError(w, r, err)
+		return
+	}
+
+	resp, err := h.handler(r.Context(), req)
+	if err != nil {
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			// don't leak the details of unexpected errors
+			err = &StatusError{Status: http.StatusInternalServerError, Err: errors.New(http.StatusText(http.StatusInternalServerError))}
+		}
+		// the status codes of errors aren't known up front, so they aren't checked against the spec
+		markRendered(r)
+		h.renderer.RenderError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(h.status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// requestPlan is where the fields of Req are set from, worked out once by Handle.
+type requestPlan struct {
+	// params are the fields tagged as parameters
+	params []paramField
+	// body are the fields of the JSON body, or nil if Req isn't a struct and all of it is the body
+	body []jsonField
+	// hasBody is false when nothing of Req is in the body
+	hasBody bool
+}
+
+type paramField struct {
+	index    []int
+	in, name string
+}
+
+func planRequest(t reflect.Type) *requestPlan {
+	plan := &requestPlan{}
+	_, body := requestFields(t)
+	plan.hasBody = body.Initialized()
+	if t.Kind() != reflect.Struct || t == timeType {
+		return plan
+	}
+	plan.body = []jsonField{}
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || isPromoted(sf) {
+			continue
+		}
+		if in, name, ok := parameterTag(sf); ok {
+			plan.params = append(plan.params, paramField{index: sf.Index, in: in, name: name})
+		} else if name, ok := jsonName(sf); ok {
+			plan.body = append(plan.body, jsonField{name: name, index: sf.Index})
+		}
+	}
+	return plan
+}
+
+// decode fills in req from the inputs validation left in the context of the request, so defaults
+// applied by validation are kept and the body isn't parsed again.
+func (p *requestPlan) decode(r *http.Request, req reflect.Value) error {
+	in := InputsFromContext(r.Context())
+	if in == nil {
+		// not validated by the router, the request is all there is
+		in = &Inputs{Header: r.Header}
+		if p.hasBody && r.Body != nil && r.Body != http.NoBody {
+			decoder := json.NewDecoder(r.Body)
+			decoder.UseNumber()
+			if err := decoder.Decode(&in.Body); err != nil {
+				return fmt.Errorf("failure decoding body: %w", err)
+			}
+		}
+	}
+
+	if p.hasBody {
+		var err error
+		if p.body == nil {
+			err = assign(req, in.Body)
+		} else if body, ok := in.Body.(map[string]interface{}); ok {
+			err = assignFields(req, p.body, body)
+		}
+		if err != nil {
+			return fmt.Errorf("failure decoding body: %w", err)
+		}
+	}
+
+	for _, param := range p.params {
+		var values []string
+		switch param.in {
+		case InPath:
+			if value, ok := in.Path[param.name]; ok {
+				values = []string{value}
+			} else if value = r.PathValue(param.name); value != "" {
+				values = []string{value}
+			}
+		case InQuery:
+			query := in.Query
+			if query == nil {
+				query = r.URL.Query()
+			}
+			values = query[param.name]
+		case InHeader:
+			values = in.Header.Values(param.name)
+		case InCookie:
+			if cookie, err := (&http.Request{Header: in.Header}).Cookie(param.name); err == nil {
+				values = []string{cookie.Value}
+			}
+		}
+		field, ok := fieldByIndex(req, param.index)
+		if !ok {
+			continue
+		}
+		if err := setStrings(field, values); err != nil {
+			return fmt.Errorf("failure decoding %v parameter %v: %w", param.in, param.name, err)
+		}
+	}
+	return nil
+}
+
+// setStrings sets v from parameter values which have already been validated.
+func setStrings(v reflect.Value, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		return setStrings(v.Elem(), values)
+	case reflect.Slice:
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setStrings(slice.Index(i), []string{value}); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.String:
+		v.SetString(values[0])
+		return nil
+	}
+
+	// everything else is decoded like JSON, quoting where needed
+	value := values[0]
+	if v.Kind() == reflect.Struct || v.Kind() == reflect.Interface {
+		value = strconv.Quote(value)
+	}
+	return json.Unmarshal([]byte(value), v.Addr().Interface())
+}
+
+// jsonField is a field of a struct and the name encoding/json gives it.
+type jsonField struct {
+	name  string
+	index []int
+}
+
+var structPlans sync.Map
+
+// jsonFields returns the fields of the struct t as encoding/json sees them, worked out once per type.
+func jsonFields(t reflect.Type) []jsonField {
+	if fields, ok := structPlans.Load(t); ok {
+		return fields.([]jsonField)
+	}
+	var fields []jsonField
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || isPromoted(sf) {
+			continue
+		}
+		if name, ok := jsonName(sf); ok {
+			fields = append(fields, jsonField{name: name, index: sf.Index})
+		}
+	}
+	structPlans.Store(t, fields)
+	return fields
+}
+
+// assign sets v from validated JSON, decoded with UseNumber, like encoding/json would decode it.
+// What it doesn't handle itself is encoded and left to encoding/json.
+func assign(v reflect.Value, value interface{}) error {
+	if value == nil {
+		// null leaves the zero value
+		return nil
+	}
+	if v.CanAddr() && v.Kind() != reflect.Pointer {
+		switch v.Addr().Interface().(type) {
+		case json.Unmarshaler, encoding.TextUnmarshaler:
+			return decodeJSON(v, value)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assign(v.Elem(), value)
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(value))
+			return nil
+		}
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			v.SetString(s)
+			return nil
+		}
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			v.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil && !v.OverflowInt(i) {
+				v.SetInt(i)
+				return nil
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n, ok := value.(json.Number); ok {
+			if i, err := strconv.ParseUint(string(n), 10, 64); err == nil && !v.OverflowUint(i) {
+				v.SetUint(i)
+				return nil
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, ok := value.(json.Number); ok {
+			if f, err := n.Float64(); err == nil && !v.OverflowFloat(f) {
+				v.SetFloat(f)
+				return nil
+			}
+		}
+	case reflect.Slice:
+		if items, ok := value.([]interface{}); ok {
+			slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+			for i, item := range items {
+				if err := assign(slice.Index(i), item); err != nil {
+					return err
+				}
+			}
+			v.Set(slice)
+			return nil
+		}
+	case reflect.Map:
+		if m, ok := value.(map[string]interface{}); ok && v.Type().Key().Kind() == reflect.String {
+			result := reflect.MakeMapWithSize(v.Type(), len(m))
+			for key, item := range m {
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if err := assign(elem, item); err != nil {
+					return err
+				}
+				result.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+			}
+			v.Set(result)
+			return nil
+		}
+	case reflect.Struct:
+		if m, ok := value.(map[string]interface{}); ok {
+			return assignFields(v, jsonFields(v.Type()), m)
+		}
+	}
+	return decodeJSON(v, value)
+}
+
+// assignFields sets the fields of the struct v from the properties of an object.
+func assignFields(v reflect.Value, fields []jsonField, properties map[string]interface{}) error {
+	for _, field := range fields {
+		value, ok := properties[field.name]
+		if !ok {
+			continue
+		}
+		f, ok := fieldByIndex(v, field.index)
+		if !ok {
+			continue
+		}
+		if err := assign(f, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeJSON sets v from value by encoding it and decoding it into v, for what assign doesn't
+// handle itself, like types with an UnmarshalJSON method. It also reports values of the wrong type.
+func decodeJSON(v reflect.Value, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v.Addr().Interface())
+}
+
+// fieldByIndex returns the field of the struct v at index, allocating the embedded structs it is
+// promoted from. It returns false if the field can't be set, e.g. in an unexported embedded struct.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, v.CanSet()
+}
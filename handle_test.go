@@ -0,0 +1,252 @@
+package crud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakecoffman/crud/option"
+)
+
+type createWidget struct {
+	Org     int      `path:"org"`
+	DryRun  bool     `query:"dryRun"`
+	Tags    []string `query:"tag"`
+	TraceID *string  `header:"X-Trace-Id"`
+
+	Name     string     `json:"name"`
+	Quantity int        `json:"quantity,omitempty" crud:"default=1"`
+	Due      *time.Time `json:"due"`
+	Secret   string     `json:"-"`
+}
+
+type widget struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Org  int    `json:"org"`
+}
+
+func TestHandle(t *testing.T) {
+	adapter := NewServeMuxAdapter()
+	router := NewRouter("title", "1.0", adapter)
+
+	var got createWidget
+	err := router.Add(Spec{
+		Method: "POST",
+		Path:   "/orgs/{org}/widgets",
+		Handler: Handle(func(ctx context.Context, req createWidget) (widget, error) {
+			got = req
+			if req.Name == "teapot" {
+				return widget{}, &StatusError{Status: http.StatusTeapot, Err: fmt.Errorf("no teapots")}
+			}
+			if req.Name == "boom" {
+				return widget{}, fmt.Errorf("secret database error")
+			}
+			return widget{ID: 1, Name: req.Name, Org: req.Org}, nil
+		}),
+		Responses: map[string]Response{
+			"201": {Description: "Created"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("describes the spec from the types", func(t *testing.T) {
+		op := router.Swagger.Paths["/orgs/{org}/widgets"].Post
+		var names []string
+		for _, p := range op.Parameters {
+			names = append(names, p.In+":"+p.Name)
+		}
+		expected := "path:org query:dryRun query:tag header:X-Trace-Id body:body"
+		if strings.Join(names, " ") != expected {
+			t.Errorf("expected %v got %v", expected, names)
+		}
+		if required := op.Parameters[0].Required; required == nil || !*required {
+			t.Errorf("expected the path parameter to be required, got %#v", op.Parameters[0])
+		}
+		if op.Responses["201"].Schema.Properties["id"].Type != KindInteger {
+			t.Errorf("expected the response to be documented, got %#v", op.Responses["201"])
+		}
+		for _, definition := range router.Swagger.Definitions {
+			if _, ok := definition.Properties["Secret"]; ok {
+				t.Errorf("expected skipped fields to be left out")
+			}
+			if definition.Properties["due"].Format != FormatDateTime {
+				t.Errorf("expected time.Time to be a dateTime")
+			}
+		}
+	})
+
+	t.Run("decodes the request and encodes the response", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/orgs/7/widgets?dryRun=true&tag=a&tag=b", strings.NewReader(`{"name":"bob","due":"2024-01-02T03:04:05Z"}`))
+		r.Header.Set("X-Trace-Id", "abc")
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+		}
+		if got.Org != 7 || !got.DryRun || len(got.Tags) != 2 || got.TraceID == nil || *got.TraceID != "abc" || got.Name != "bob" || got.Due.Year() != 2024 || got.Quantity != 1 {
+			t.Errorf("unexpected request %#v", got)
+		}
+		var resp widget
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp != (widget{ID: 1, Name: "bob", Org: 7}) {
+			t.Errorf("unexpected response %v", w.Body.String())
+		}
+	})
+
+	t.Run("validates the request", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/orgs/seven/widgets", strings.NewReader(`{"name":1}`))
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("renders errors", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/orgs/7/widgets", strings.NewReader(`{"name":"teapot"}`))
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+		if w.Code != http.StatusTeapot || !strings.Contains(w.Body.String(), "no teapots") {
+			t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+		}
+
+		r = httptest.NewRequest("POST", "/orgs/7/widgets", strings.NewReader(`{"name":"boom"}`))
+		w = httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+		if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestHandle_NonStruct(t *testing.T) {
+	adapter := NewServeMuxAdapter()
+	router := NewRouter("title", "1.0", adapter)
+
+	err := router.Add(Spec{
+		Method: "POST",
+		Path:   "/sum",
+		Handler: Handle(func(ctx context.Context, req []float64) (float64, error) {
+			var sum float64
+			for _, v := range req {
+				sum += v
+			}
+			return sum, nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("POST", "/sum", strings.NewReader(`[1,2,3.5]`)))
+	if w.Code != http.StatusOK || w.Body.String() != "6.5\n" {
+		t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("POST", "/sum", strings.NewReader(`[1,"a"]`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestHandle_Specs(t *testing.T) {
+	adapter := NewServeMuxAdapter()
+	router := NewRouter("title", "1.0", adapter)
+
+	// one handler added to specs with different statuses and error renderers
+	handler := Handle(func(ctx context.Context, req struct {
+		Name string `json:"name"`
+	}) (widget, error) {
+		if req.Name == "teapot" {
+			return widget{}, &StatusError{Status: http.StatusTeapot, Err: fmt.Errorf("no teapots")}
+		}
+		return widget{Name: req.Name}, nil
+	})
+	err := router.Add(Spec{
+		Method:    "POST",
+		Path:      "/created",
+		Handler:   handler,
+		Responses: map[string]Response{"201": {Description: "Created"}},
+	}, Spec{
+		Method:  "POST",
+		Path:    "/ok",
+		Handler: handler,
+		Options: []option.Option{ErrorRendererOption(JSONErrorRenderer{})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Path string
+		Body string
+		Code int
+		Type string
+	}{
+		{Path: "/created", Body: `{"name":"bob"}`, Code: http.StatusCreated, Type: "application/json"},
+		{Path: "/ok", Body: `{"name":"bob"}`, Code: http.StatusOK, Type: "application/json"},
+		{Path: "/created", Body: `{"name":"teapot"}`, Code: http.StatusTeapot, Type: "application/problem+json"},
+		{Path: "/ok", Body: `{"name":"teapot"}`, Code: http.StatusTeapot, Type: "application/json"},
+		{Path: "/ok", Body: `{"name":1}`, Code: http.StatusBadRequest, Type: "application/json"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, httptest.NewRequest("POST", test.Path, strings.NewReader(test.Body)))
+		if w.Code != test.Code || w.Header().Get("Content-Type") != test.Type {
+			t.Errorf("%v %v: expected %v %v got %v %v %v", test.Path, test.Body, test.Code, test.Type, w.Code, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+}
+
+func TestHandle_ValidateResponses(t *testing.T) {
+	adapter := NewServeMuxAdapter()
+	router := NewRouter("title", "1.0", adapter, option.ValidateResponses(1))
+
+	err := router.Add(Spec{
+		Method: "GET",
+		Path:   "/widgets/{id}",
+		Handler: Handle(func(ctx context.Context, req struct {
+			ID int `path:"id"`
+		}) (widget, error) {
+			if req.ID == 404 {
+				return widget{}, &StatusError{Status: http.StatusNotFound, Err: fmt.Errorf("no widget %v", req.ID)}
+			}
+			if req.ID == 500 {
+				return widget{}, fmt.Errorf("secret database error")
+			}
+			return widget{ID: req.ID}, nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Path string
+		Code int
+	}{
+		{Path: "/widgets/1", Code: http.StatusOK},
+		{Path: "/widgets/404", Code: http.StatusNotFound},
+		{Path: "/widgets/500", Code: http.StatusInternalServerError},
+		{Path: "/widgets/a", Code: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", test.Path, nil))
+		if w.Code != test.Code || strings.Contains(w.Body.String(), "not documented") {
+			t.Errorf("%v: expected %v got %v %v", test.Path, test.Code, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package crud
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// limits are the limits of JSON bodies, see option.MaxBodyBytes and the others. Zero is no limit.
+type limits struct {
+	bytes        int64
+	depth        int
+	elements     int
+	stringLength int
+}
+
+// tooLarge is the error for a body of more than l.bytes, rendered as 413 Request Entity Too Large.
+func (l limits) tooLarge() error {
+	return &ValidationError{In: InBody, Rule: "maxBodyBytes", Limit: l.bytes, Err: ErrBodyTooLarge}
+}
+
+// scan checks the JSON body for values past the limits, and if strict is true for what
+// option.StrictJSON rejects, before it is decoded. Malformed JSON isn't reported here, the
+// decoder does that.
+func scan(data []byte, l limits, strict bool) error {
+	if l.depth <= 0 && l.elements <= 0 && l.stringLength <= 0 && !strict {
+		return nil
+	}
+	s := &scanner{limits: l, strict: strict, data: data}
+	err := s.value(0)
+	if err == errMalformed {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.skipSpace(); strict && s.pos < len(s.data) {
+		return &ValidationError{In: InBody, Rule: "trailingData", Err: ErrTrailingData}
+	}
+	return nil
+}
+
+// describeBody documents the limits and strictness of JSON bodies, or returns "" if there are none.
+func (s settings) describeBody() string {
+	description := s.limits.describe()
+	if s.strictJSON {
+		description = strings.TrimSpace(description + " Keys must be unique, nothing can follow the body and strings must be valid UTF-8.")
+	}
+	return description
+}
+
+// describe returns a sentence documenting the limits, or "" if there are none.
+func (l limits) describe() string {
+	var parts []string
+	if l.bytes > 0 {
+		parts = append(parts, fmt.Sprintf("%v bytes", l.bytes))
+	}
+	if l.depth > 0 {
+		parts = append(parts, fmt.Sprintf("a depth of %v", l.depth))
+	}
+	if l.elements > 0 {
+		parts = append(parts, fmt.Sprintf("%v properties and items", l.elements))
+	}
+	if l.stringLength > 0 {
+		parts = append(parts, fmt.Sprintf("strings of %v characters", l.stringLength))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	if len(parts) > 1 {
+		parts = append(parts[:len(parts)-2], parts[len(parts)-2]+" and "+parts[len(parts)-1])
+	}
+	return fmt.Sprintf("The body can have at most %v.", strings.Join(parts, ", "))
+}
+
+// errMalformed stops the scan of a body that isn't JSON.
+var errMalformed = errors.New("malformed JSON")
+
+// maxScanDepth is where encoding/json gives up on nesting, the scan stops there too.
+const maxScanDepth = 10000
+
+// scanner checks the limits of a JSON body in one pass over its bytes, which costs far less
+// than decoding it and rejects what is too big before anything is allocated for it.
+type scanner struct {
+	limits
+	strict   bool
+	data     []byte
+	pos      int
+	elements int
+	// path leads to the value being scanned, for the pointer of errors
+	path []step
+}
+
+// step is a property or item of the path to a value.
+type step struct {
+	// key is the property as written, quoted and escaped, or nil for an item
+	key   []byte
+	index int
+}
+
+// value scans the value at pos, inside depth objects and arrays.
+func (s *scanner) value(depth int) error {
+	s.skipSpace()
+	if s.pos >= len(s.data) {
+		return errMalformed
+	}
+	switch s.data[s.pos] {
+	case '{', '[':
+		if s.limits.depth > 0 && depth >= s.limits.depth {
+			return s.fail("maxDepth", s.limits.depth, ErrTooDeep)
+		}
+		if depth >= maxScanDepth {
+			return errMalformed
+		}
+		if s.data[s.pos] == '{' {
+			return s.object(depth + 1)
+		}
+		return s.array(depth + 1)
+	case '"':
+		raw, length, err := s.string()
+		if err != nil {
+			return err
+		}
+		if err := s.checkUTF8(raw); err != nil {
+			return err
+		}
+		return s.checkLength(length)
+	}
+	// numbers, true, false and null
+	start := s.pos
+	for s.pos < len(s.data) && !isDelimiter(s.data[s.pos]) {
+		s.pos++
+	}
+	if s.pos == start {
+		return errMalformed
+	}
+	return nil
+}
+
+func (s *scanner) object(depth int) error {
+	s.pos++
+	s.skipSpace()
+	if s.pos < len(s.data) && s.data[s.pos] == '}' {
+		s.pos++
+		return nil
+	}
+	// the keys seen so far, to find duplicates when strict
+	var keys map[string]struct{}
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.data) || s.data[s.pos] != '"' {
+			return errMalformed
+		}
+		key, length, err := s.string()
+		if err != nil {
+			return err
+		}
+		s.path = append(s.path, step{key: key})
+		if err := s.count(); err != nil {
+			return err
+		}
+		if err := s.checkUTF8(key); err != nil {
+			return err
+		}
+		if err := s.checkLength(length); err != nil {
+			return err
+		}
+		if s.strict {
+			if keys == nil {
+				keys = map[string]struct{}{}
+			}
+			name := unquote(key)
+			if _, ok := keys[name]; ok {
+				return s.fail("duplicateKey", nil, ErrDuplicateKey)
+			}
+			keys[name] = struct{}{}
+		}
+		s.skipSpace()
+		if s.pos >= len(s.data) || s.data[s.pos] != ':' {
+			return errMalformed
+		}
+		s.pos++
+		if err := s.value(depth); err != nil {
+			return err
+		}
+		s.path = s.path[:len(s.path)-1]
+		if done, err := s.next('}'); done || err != nil {
+			return err
+		}
+	}
+}
+
+func (s *scanner) array(depth int) error {
+	s.pos++
+	s.skipSpace()
+	if s.pos < len(s.data) && s.data[s.pos] == ']' {
+		s.pos++
+		return nil
+	}
+	for i := 0; ; i++ {
+		s.path = append(s.path, step{index: i})
+		if err := s.count(); err != nil {
+			return err
+		}
+		if err := s.value(depth); err != nil {
+			return err
+		}
+		s.path = s.path[:len(s.path)-1]
+		if done, err := s.next(']'); done || err != nil {
+			return err
+		}
+	}
+}
+
+// next moves past the comma before the next property or item, or the end of the object or array.
+func (s *scanner) next(end byte) (done bool, err error) {
+	s.skipSpace()
+	if s.pos >= len(s.data) {
+		return false, errMalformed
+	}
+	switch s.data[s.pos] {
+	case ',':
+		s.pos++
+		return false, nil
+	case end:
+		s.pos++
+		return true, nil
+	}
+	return false, errMalformed
+}
+
+// string moves past the string at pos, returning it as written and its length in characters.
+func (s *scanner) string() (raw []byte, length int, err error) {
+	start := s.pos
+	s.pos++
+	for s.pos < len(s.data) {
+		switch c := s.data[s.pos]; {
+		case c == '"':
+			s.pos++
+			return s.data[start:s.pos], length, nil
+		case c == '\\':
+			if s.pos+1 < len(s.data) && s.data[s.pos+1] == 'u' {
+				if s.pos+6 > len(s.data) {
+					return nil, 0, errMalformed
+				}
+				// a high surrogate is one character with the low surrogate after it
+				if hex := s.data[s.pos+2 : s.pos+4]; !(hex[0] == 'd' || hex[0] == 'D') || !strings.ContainsRune("89abAB", rune(hex[1])) {
+					length++
+				}
+				s.pos += 6
+			} else {
+				length++
+				s.pos += 2
+			}
+		case c&0xC0 != 0x80:
+			// the first byte of a UTF-8 encoded character
+			length++
+			s.pos++
+		default:
+			s.pos++
+		}
+	}
+	return nil, 0, errMalformed
+}
+
+// count counts a property or item against the limit of elements.
+func (s *scanner) count() error {
+	s.elements++
+	if s.limits.elements > 0 && s.elements > s.limits.elements {
+		return s.fail("maxElements", s.limits.elements, ErrTooManyValues)
+	}
+	return nil
+}
+
+// checkUTF8 rejects strings and keys that aren't valid UTF-8 when strict, either as written or
+// escaped. encoding/json would replace the invalid bytes and the lone surrogates with U+FFFD.
+func (s *scanner) checkUTF8(raw []byte) error {
+	if s.strict && (!utf8.Valid(raw) || !pairedSurrogates(raw)) {
+		return s.fail("utf8", nil, ErrInvalidUTF8)
+	}
+	return nil
+}
+
+// pairedSurrogates returns false if the string has an escaped high surrogate \uD800-\uDBFF that
+// isn't followed by an escaped low surrogate \uDC00-\uDFFF, or a low surrogate without a high one.
+// The escapes are known to be complete, the string was scanned already.
+func pairedSurrogates(raw []byte) bool {
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			continue
+		}
+		if raw[i+1] != 'u' {
+			// past the escaped character, which may be a backslash
+			i++
+			continue
+		}
+		r := hexRune(raw[i+2 : i+6])
+		switch {
+		case r >= 0xD800 && r <= 0xDBFF:
+			if i+12 > len(raw) || raw[i+6] != '\\' || raw[i+7] != 'u' {
+				return false
+			}
+			if low := hexRune(raw[i+8 : i+12]); low < 0xDC00 || low > 0xDFFF {
+				return false
+			}
+			i += 11
+		case r >= 0xDC00 && r <= 0xDFFF:
+			return false
+		default:
+			i += 5
+		}
+	}
+	return true
+}
+
+// hexRune decodes the four hex digits of a \u escape, or returns -1 if they aren't hex digits.
+func hexRune(hex []byte) rune {
+	r, err := strconv.ParseUint(string(hex), 16, 16)
+	if err != nil {
+		return -1
+	}
+	return rune(r)
+}
+
+// checkLength checks the length of a string or key against the limit.
+func (s *scanner) checkLength(length int) error {
+	if s.limits.stringLength > 0 && length > s.limits.stringLength {
+		return s.fail("maxStringLength", s.limits.stringLength, ErrStringTooLong)
+	}
+	return nil
+}
+
+func (s *scanner) skipSpace() {
+	for s.pos < len(s.data) && isSpace(s.data[s.pos]) {
+		s.pos++
+	}
+}
+
+// fail returns the error of the rule for the value at the path.
+func (s *scanner) fail(rule string, limit interface{}, err error) error {
+	pointer := ""
+	for _, step := range s.path {
+		if step.key == nil {
+			pointer = joinPointer(pointer, step.index)
+			continue
+		}
+		pointer = joinPointer(pointer, unquote(step.key))
+	}
+	return &ValidationError{In: InBody, Pointer: pointer, Rule: rule, Limit: limit, Err: err}
+}
+
+// unquote returns the string a key of the body is, the quotes removed and escapes decoded.
+func unquote(raw []byte) string {
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return string(raw[1 : len(raw)-1])
+	}
+	var key string
+	if json.Unmarshal(raw, &key) != nil {
+		return string(raw)
+	}
+	return key
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// isDelimiter returns true for the bytes that end a number or literal.
+func isDelimiter(c byte) bool {
+	return isSpace(c) || c == ',' || c == ':' || c == '"' || c == '[' || c == ']' || c == '{' || c == '}'
+}
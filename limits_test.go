@@ -0,0 +1,187 @@
+package crud
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jakecoffman/crud/option"
+)
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		Limits   limits
+		Input    string
+		Pointer  string
+		Rule     string
+		Expected error
+	}{
+		{Limits: limits{depth: 2}, Input: `{"a":{"b":1},"c":[1]}`},
+		{Limits: limits{depth: 2}, Input: `{"a":{"b":[1]}}`, Pointer: "/a/b", Rule: "maxDepth", Expected: ErrTooDeep},
+		{Limits: limits{depth: 1}, Input: `[[]]`, Pointer: "/0", Rule: "maxDepth", Expected: ErrTooDeep},
+		{Limits: limits{elements: 4}, Input: `{"a":1,"b":[1,2]}`},
+		{Limits: limits{elements: 3}, Input: `{"a":1,"b":[1,2]}`, Pointer: "/b/1", Rule: "maxElements", Expected: ErrTooManyValues},
+		{Limits: limits{stringLength: 5}, Input: `["héllo", "é\n😀", "12345"]`},
+		{Limits: limits{stringLength: 5}, Input: `{"a":["123456"]}`, Pointer: "/a/0", Rule: "maxStringLength", Expected: ErrStringTooLong},
+		{Limits: limits{stringLength: 5}, Input: `{"a/b~":{"123456":1}}`, Pointer: "/a~1b~0/123456", Rule: "maxStringLength", Expected: ErrStringTooLong},
+		{Limits: limits{stringLength: 5}, Input: `"12345\""`, Rule: "maxStringLength", Expected: ErrStringTooLong},
+		// malformed bodies are left to the decoder
+		{Limits: limits{depth: 1}, Input: `{"a":`},
+		{Limits: limits{depth: 1}, Input: `{"a" 1}`},
+	}
+
+	for _, test := range tests {
+		err := scan([]byte(test.Input), test.Limits, false)
+		if !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", test.Input, test.Expected, err)
+			continue
+		}
+		var validationErr *ValidationError
+		if test.Expected != nil && errors.As(err, &validationErr) && (validationErr.Pointer != test.Pointer || validationErr.Rule != test.Rule) {
+			t.Errorf("%v: expected %q at %q, got %q at %q", test.Input, test.Rule, test.Pointer, validationErr.Rule, validationErr.Pointer)
+		}
+	}
+}
+
+func TestLimits_Router(t *testing.T) {
+	adapter := NewServeMuxAdapter()
+	router := NewRouter("title", "1.0", adapter, option.MaxBodyBytes(16), option.MaxDepth(1))
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}
+	body := Object(map[string]Field{"a": String(), "b": Object(map[string]Field{})})
+	err := router.Add(Spec{
+		Method:   "POST",
+		Path:     "/limited",
+		Handler:  handler,
+		Validate: Validate{Body: body},
+	}, Spec{
+		Method:   "POST",
+		Path:     "/unlimited",
+		Handler:  handler,
+		Validate: Validate{Body: body},
+		Options:  []option.Option{option.MaxBodyBytes(0), option.MaxDepth(0)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Path   string
+		Body   string
+		Length int64
+		Code   int
+	}{
+		{Path: "/limited", Body: `{"a":"12345678"}`, Code: http.StatusOK},
+		{Path: "/limited", Body: `{"a":"123456789"}`, Code: http.StatusRequestEntityTooLarge},
+		// without a Content-Length the body is read up to the limit
+		{Path: "/limited", Body: `{"a":"123456789"}`, Length: -1, Code: http.StatusRequestEntityTooLarge},
+		{Path: "/limited", Body: `{"b":{}}`, Code: http.StatusBadRequest},
+		{Path: "/unlimited", Body: `{"a":"1234567890","b":{}}`, Code: http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", test.Path, strings.NewReader(test.Body))
+		if test.Length != 0 {
+			r.ContentLength = test.Length
+		}
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+		if w.Code != test.Code {
+			t.Errorf("%v %v: expected %v got %v %v", test.Path, test.Body, test.Code, w.Code, w.Body.String())
+		}
+	}
+
+	operation := router.Swagger.Paths["/limited"].Post
+	if _, ok := operation.Responses["413"]; !ok {
+		t.Errorf("expected the 413 response to be documented")
+	}
+	if description := operation.Parameters[0].Description; description != "The body can have at most 16 bytes and a depth of 1." {
+		t.Errorf("unexpected description %q", description)
+	}
+	if description := router.OpenAPI.Paths["/limited"].Post.RequestBody.Description; description != operation.Parameters[0].Description {
+		t.Errorf("unexpected description of the request body %q", description)
+	}
+	if _, ok := router.Swagger.Paths["/unlimited"].Post.Responses["413"]; ok {
+		t.Errorf("expected no 413 response without a limit")
+	}
+}
+
+func TestStrictJSON(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Pointer  string
+		Rule     string
+		Expected error
+	}{
+		{Input: `{"a":1,"b":[{"a":1},{"a":1}]} ` + "\n"},
+		{Input: `{"a":1,"a":2}`, Pointer: "/a", Rule: "duplicateKey", Expected: ErrDuplicateKey},
+		{Input: `{"a":{"b":1,"b":2}}`, Pointer: "/a/b", Rule: "duplicateKey", Expected: ErrDuplicateKey},
+		{Input: `{"a":1} {"b":2}`, Rule: "trailingData", Expected: ErrTrailingData},
+		{Input: `{"a":1}]`, Rule: "trailingData", Expected: ErrTrailingData},
+		{Input: "{\"a\":[\"\xff\"]}", Pointer: "/a/0", Rule: "utf8", Expected: ErrInvalidUTF8},
+		{Input: "{\"\xff\":1}", Pointer: "/\xff", Rule: "utf8", Expected: ErrInvalidUTF8},
+		{Input: `{"a":"\ud83d\ude00 \\ud800 \u00e9"}`},
+		{Input: `{"a":"\ud800"}`, Pointer: "/a", Rule: "utf8", Expected: ErrInvalidUTF8},
+		{Input: `{"a":"\uDBFFx"}`, Pointer: "/a", Rule: "utf8", Expected: ErrInvalidUTF8},
+		{Input: `{"a":"\ud800\u0041"}`, Pointer: "/a", Rule: "utf8", Expected: ErrInvalidUTF8},
+		{Input: `{"a":"\ud800\ud800"}`, Pointer: "/a", Rule: "utf8", Expected: ErrInvalidUTF8},
+		{Input: `{"a":["\udc00"]}`, Pointer: "/a/0", Rule: "utf8", Expected: ErrInvalidUTF8},
+		{Input: `{"\ud800":1}`, Pointer: "/\ufffd", Rule: "utf8", Expected: ErrInvalidUTF8},
+	}
+
+	for _, test := range tests {
+		err := scan([]byte(test.Input), limits{}, true)
+		if !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", test.Input, test.Expected, err)
+			continue
+		}
+		var validationErr *ValidationError
+		if test.Expected != nil && errors.As(err, &validationErr) && (validationErr.Pointer != test.Pointer || validationErr.Rule != test.Rule) {
+			t.Errorf("%v: expected %q at %q, got %q at %q", test.Input, test.Rule, test.Pointer, validationErr.Rule, validationErr.Pointer)
+		}
+		// without strict, encoding/json decides
+		if err := scan([]byte(test.Input), limits{}, false); err != nil {
+			t.Errorf("%v: expected no error without strict, got %v", test.Input, err)
+		}
+	}
+
+	adapter := NewServeMuxAdapter()
+	router := NewRouter("title", "1.0", adapter, option.StrictJSON(true))
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}
+	body := Object(map[string]Field{"a": Integer()})
+	err := router.Add(Spec{
+		Method:   "POST",
+		Path:     "/strict",
+		Handler:  handler,
+		Validate: Validate{Body: body},
+	}, Spec{
+		Method:   "POST",
+		Path:     "/lenient",
+		Handler:  handler,
+		Validate: Validate{Body: body},
+		Options:  []option.Option{option.StrictJSON(false)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("POST", "/strict", strings.NewReader(`{"a":1,"a":2}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"pointer":"/a","rule":"duplicateKey"`) {
+		t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("POST", "/lenient", strings.NewReader(`{"a":1,"a":2}`)))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+
+	if description := router.Swagger.Paths["/strict"].Post.Parameters[0].Description; !strings.Contains(description, "Keys must be unique") {
+		t.Errorf("unexpected description %q", description)
+	}
+}
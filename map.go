@@ -0,0 +1,47 @@
+package crud
+
+import "regexp"
+
+// Map creates an object field with arbitrary keys, each value must match the value field.
+// Min and Max limit the number of properties, KeyPattern restricts the keys.
+func Map(value Field) Field {
+	return Field{kind: KindObject, obj: map[string]Field{}, values: &value}
+}
+
+// KeyPattern specifies a regex pattern the keys of a Map must match
+func (f Field) KeyPattern(pattern string) Field {
+	if f.values == nil {
+		panic("KeyPattern can only be used with maps")
+	}
+	f.keyPattern = regexp.MustCompile(pattern)
+	return f
+}
+
+// validateMap validates the keys and the values of a map. Properties of the object and the number
+// of properties are validated by validateObject, the values validated here are the rest.
+func validateMap(loc *location, field *Field, v map[string]interface{}, c *collector) error {
+	// values inherit the settings like children do
+	value := *field.values
+	if value.strip == nil {
+		value.strip = field.strip
+	}
+	if value.unknown == nil {
+		value.unknown = field.unknown
+	}
+	key := Field{kind: KindString, pattern: field.keyPattern}
+	for _, name := range sortedKeys(v) {
+		if _, ok := field.obj[name]; ok {
+			continue
+		}
+		if key.pattern != nil && !key.pattern.MatchString(name) {
+			if err := c.add(newValidationError(InBody, loc.property(name).pointer(), &key, name, ErrPattern)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := validateObject(loc.property(name), &value, v[name], c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
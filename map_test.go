@@ -0,0 +1,99 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+	labels := Object(map[string]Field{
+		"labels": Map(String().Max(5)).KeyPattern("^[a-z]+$").Min(1).Max(2),
+		"flags":  Map(Object(map[string]Field{"on": Boolean().Required()})),
+	})
+
+	tests := []struct {
+		Input    string
+		Expected error
+		Pointer  string
+	}{
+		{Input: `{"labels":{"env":"prod"}}`},
+		{Input: `{"labels":{"env":"prod","team":"web"},"flags":{"beta":{"on":true}}}`},
+		{Input: `{"labels":{}}`, Expected: ErrMinimum, Pointer: "/labels"},
+		{Input: `{"labels":{"a":"1","b":"2","c":"3"}}`, Expected: ErrMaximum, Pointer: "/labels"},
+		{Input: `{"labels":{"Env":"prod"}}`, Expected: ErrPattern, Pointer: "/labels/Env"},
+		{Input: `{"labels":{"env":"production"}}`, Expected: ErrMaximum, Pointer: "/labels/env"},
+		{Input: `{"labels":{"env":1}}`, Expected: ErrWrongType, Pointer: "/labels/env"},
+		{Input: `{"labels":[]}`, Expected: ErrWrongType, Pointer: "/labels"},
+		{Input: `{"flags":{"beta":{}}}`, Expected: ErrRequired, Pointer: "/flags/beta/on"},
+	}
+
+	for i, test := range tests {
+		var body interface{}
+		_ = json.Unmarshal([]byte(test.Input), &body)
+		err := r.Validate(Validate{Body: labels}, nil, body, nil)
+		if !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", i, test.Expected, err)
+		}
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) && validationErr.Pointer != test.Pointer {
+			t.Errorf("%v: expected pointer %v got %v", i, test.Pointer, validationErr.Pointer)
+		}
+	}
+
+	// values are stripped like other objects, but keys of the map never are
+	var body interface{}
+	_ = json.Unmarshal([]byte(`{"flags":{"beta":{"on":true,"extra":1}}}`), &body)
+	if err := r.Validate(Validate{Body: labels}, nil, body, nil); err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]interface{}{"flags": map[string]interface{}{"beta": map[string]interface{}{"on": true}}}; !reflect.DeepEqual(body, expected) {
+		t.Errorf("expected %v got %v", expected, body)
+	}
+}
+
+func TestMap_Schema(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+	err := r.Add(Spec{
+		Method:   "POST",
+		Path:     "/labels",
+		Validate: Validate{Body: Map(String()).KeyPattern("^[a-z]+$").Max(10)},
+		Responses: map[string]Response{
+			"200": {Description: "OK", Schema: JsonSchema{Type: KindObject, AdditionalProperties: &JsonSchema{Type: KindInteger}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref := r.Swagger.Paths["/labels"].Post.Parameters[0].Schema.Ref[len("#/definitions/"):]
+	schema := r.Swagger.Definitions[ref]
+	if schema.AdditionalProperties == nil || schema.AdditionalProperties.Type != KindString || *schema.MaxProperties != 10 || schema.Maximum != nil {
+		t.Errorf("unexpected schema %#v", schema)
+	}
+	openapi := r.OpenAPI.Components.Schemas[ref]
+	if openapi.PropertyNames.Pattern != "^[a-z]+$" || openapi.AdditionalProperties.Type[0] != KindString {
+		t.Errorf("unexpected schema %#v", openapi)
+	}
+
+	spec := &Spec{Responses: r.Swagger.Paths["/labels"].Post.Responses}
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	if err = r.CheckResponse(spec, 200, header, []byte(`{"a":1}`)); err != nil {
+		t.Error(err)
+	}
+	if err = r.CheckResponse(spec, 200, header, []byte(`{"a":"1"}`)); !errors.Is(err, ErrWrongType) {
+		t.Errorf("expected %v got %v", ErrWrongType, err)
+	}
+
+	type counts struct {
+		ByName map[string]int `json:"byName"`
+		ByID   map[int]string `json:"byId"`
+	}
+	f := FromStruct(counts{})
+	if f.obj["byName"].values.kind != KindInteger || f.obj["byId"].keyPattern == nil {
+		t.Errorf("expected Go maps to be maps, got %#v", f.obj)
+	}
+}
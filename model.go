@@ -0,0 +1,113 @@
+package crud
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Model names a field so it is added to the swagger definitions once and referenced with $ref
+// wherever it is used, in bodies, nested objects and array items alike. Using the same name for
+// fields with different schemas is an error when the route is added.
+func Model(name string, field Field) Field {
+	if name == "" {
+		panic("model name must not be empty")
+	}
+	field.name = name
+	return field
+}
+
+// definitions collects the schemas of models while fields are transformed into schemas.
+type definitions struct {
+	schemas map[string]JsonSchema
+	err     error
+}
+
+// ref adds the model to the definitions and returns a schema referencing it.
+func (d *definitions) ref(f *Field) JsonSchema {
+	// the model is the same wherever it is used, only the reference to it can be nullable
+	inline := *f
+	inline.name = ""
+	inline.nullable = false
+	if f.discriminator != "" {
+		return d.discriminated(f.name, f)
+	}
+	d.add(f.name, inline.toJsonSchema(d))
+	return JsonSchema{Ref: "#/definitions/" + f.name, XNullable: f.nullable}
+}
+
+// discriminated adds the definitions Swagger 2.0 describes a Discriminated field with and returns a
+// schema referencing them. The field is the definition name, the base with the discriminator, and each
+// variant is a definition extending it with allOf, named after the base and its value. Clients find the
+// variants by the definitions extending the base. Without a name the field is named by its content.
+func (d *definitions) discriminated(name string, f *Field) JsonSchema {
+	base := JsonSchema{
+		Type:          KindObject,
+		Description:   f.describe(),
+		Properties:    map[string]JsonSchema{f.discriminator: {Type: KindString, Enum: f.discriminatorValues()}},
+		Required:      []string{f.discriminator},
+		Discriminator: f.discriminator,
+	}
+	values := sortedKeys(f.variants)
+	variants := make([]JsonSchema, len(values))
+	for i, value := range values {
+		variant := f.variants[value]
+		variants[i] = variant.toJsonSchema(d)
+	}
+	if name == "" {
+		data, err := json.Marshal([]interface{}{base, variants})
+		if err != nil && d.err == nil {
+			d.err = err
+		}
+		sum := sha256.Sum256(data)
+		name = fmt.Sprintf("Model-%x", sum[:6])
+	}
+
+	d.add(name, base)
+	ref := JsonSchema{Ref: "#/definitions/" + name}
+	for i, value := range values {
+		d.add(name+exported(value), JsonSchema{
+			AllOf:               []JsonSchema{ref, variants[i]},
+			XDiscriminatorValue: value,
+		})
+	}
+	ref.XNullable = f.nullable
+	return ref
+}
+
+// exported returns s with its first letter in upper case, like the names of exported Go identifiers.
+func exported(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// add adds the schema to the definitions unless it is already there.
+func (d *definitions) add(name string, schema JsonSchema) {
+	if existing, ok := d.schemas[name]; ok && !reflect.DeepEqual(existing, schema) {
+		if d.err == nil {
+			d.err = fmt.Errorf("model %v is defined more than once with different schemas", name)
+		}
+		return
+	}
+	d.schemas[name] = schema
+}
+
+// body returns a reference to the definition of the body, which is named by its content
+// when it isn't a model so routes with identical bodies share a definition.
+func (d *definitions) body(f *Field) (JsonSchema, error) {
+	schema := f.toJsonSchema(d)
+	if schema.Ref == "" {
+		data, err := json.Marshal(schema)
+		if err != nil {
+			return schema, err
+		}
+		sum := sha256.Sum256(data)
+		name := fmt.Sprintf("Model-%x", sum[:6])
+		d.add(name, schema)
+		schema = JsonSchema{Ref: "#/definitions/" + name}
+	}
+	return schema, d.err
+}
@@ -0,0 +1,124 @@
+package crud
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModel(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+
+	part := Model("Part", Object(map[string]Field{
+		"serial": String().Required(),
+	}))
+	widget := Model("Widget", Object(map[string]Field{
+		"name":  String().Required(),
+		"main":  part,
+		"parts": Array().Items(part),
+	}))
+
+	err := r.Add(Spec{
+		Method:   "POST",
+		Path:     "/widgets",
+		Validate: Validate{Body: widget},
+	}, Spec{
+		Method:   "PUT",
+		Path:     "/widgets",
+		Validate: Validate{Body: widget},
+	}, Spec{
+		Method: "POST",
+		Path:   "/gadgets",
+		Validate: Validate{Body: Object(map[string]Field{
+			"widget": widget,
+		})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	definitions := r.Swagger.Definitions
+	if len(definitions) != 3 {
+		t.Errorf("expected 3 definitions, got %v", len(definitions))
+	}
+	if ref := r.Swagger.Paths["/widgets"].Post.Parameters[0].Schema.Ref; ref != "#/definitions/Widget" {
+		t.Errorf("unexpected ref %v", ref)
+	}
+	if ref := r.Swagger.Paths["/widgets"].Put.Parameters[0].Schema.Ref; ref != "#/definitions/Widget" {
+		t.Errorf("unexpected ref %v", ref)
+	}
+	w := definitions["Widget"]
+	if w.Properties["main"].Ref != "#/definitions/Part" || w.Properties["parts"].Items.Ref != "#/definitions/Part" {
+		t.Errorf("expected nested models to be referenced, got %#v", w.Properties)
+	}
+	if !reflect.DeepEqual(w.Required, []string{"name"}) {
+		t.Errorf("unexpected required %v", w.Required)
+	}
+	if definitions["Part"].Properties["serial"].Type != KindString {
+		t.Errorf("unexpected part %#v", definitions["Part"])
+	}
+
+	if err := part.Validate(map[string]interface{}{}); err == nil {
+		t.Errorf("expected models to validate like the field they name")
+	}
+}
+
+func TestModel_Anonymous(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+	body := func() Field {
+		return Object(map[string]Field{
+			"a": String(),
+			"b": Integer().Required(),
+		})
+	}
+
+	err := r.Add(Spec{
+		Method:   "POST",
+		Path:     "/a",
+		Validate: Validate{Body: body()},
+	}, Spec{
+		Method:   "POST",
+		Path:     "/b",
+		Validate: Validate{Body: body()},
+	}, Spec{
+		Method:   "POST",
+		Path:     "/c",
+		Validate: Validate{Body: Array().Items(String())},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.Swagger.Definitions) != 2 {
+		t.Errorf("expected identical bodies to share a definition, got %v", len(r.Swagger.Definitions))
+	}
+	a := r.Swagger.Paths["/a"].Post.Parameters[0].Schema.Ref
+	if a != r.Swagger.Paths["/b"].Post.Parameters[0].Schema.Ref || a == r.Swagger.Paths["/c"].Post.Parameters[0].Schema.Ref {
+		t.Errorf("unexpected refs")
+	}
+
+	// names don't depend on the order routes are added
+	other := NewRouter("", "", &TestAdapter{})
+	if err = other.Add(Spec{Method: "POST", Path: "/b", Validate: Validate{Body: body()}}); err != nil {
+		t.Fatal(err)
+	}
+	if ref := other.Swagger.Paths["/b"].Post.Parameters[0].Schema.Ref; ref != a {
+		t.Errorf("expected %v got %v", a, ref)
+	}
+}
+
+func TestModel_Conflict(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+
+	err := r.Add(Spec{
+		Method:   "POST",
+		Path:     "/a",
+		Validate: Validate{Body: Model("Widget", Object(map[string]Field{"a": String()}))},
+	}, Spec{
+		Method:   "POST",
+		Path:     "/b",
+		Validate: Validate{Body: Model("Widget", Object(map[string]Field{"b": String()}))},
+	})
+	if err == nil {
+		t.Errorf("expected an error for conflicting models")
+	}
+}
@@ -0,0 +1,184 @@
+package crud
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// maxExactInteger is the largest integer float64 holds exactly, larger integers are compared as big.Int.
+const maxExactInteger = 1 << 53
+
+// validateJSONNumber checks a number as it was written in JSON. Integers are checked exactly,
+// also past 2^53 where float64 would round them.
+func (f *Field) validateJSONNumber(v json.Number) error {
+	switch f.kind {
+	case KindInteger:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return f.validateInteger(n)
+		}
+		if plainInteger(v) {
+			n, _ := new(big.Int).SetString(string(v), 10)
+			return f.validateBigInteger(n)
+		}
+		// fractions and exponents, like 1.0 or 1e3, are as exact as float64 is
+		n, err := v.Float64()
+		if err != nil || n != math.Trunc(n) {
+			return ErrWrongType
+		}
+		if math.Abs(n) <= maxExactInteger {
+			return f.validateInteger(int64(n))
+		}
+		exact, _ := big.NewFloat(n).Int(nil)
+		return f.validateBigInteger(exact)
+	case KindNumber:
+		n, err := v.Float64()
+		if err != nil {
+			return ErrWrongType
+		}
+		return f.validateNumber(n)
+	}
+	return ErrWrongType
+}
+
+// validateInteger checks the bounds and format of an integer.
+func (f *Field) validateInteger(n int64) error {
+	if n < -maxExactInteger || n > maxExactInteger {
+		return f.validateBigInteger(big.NewInt(n))
+	}
+	if n < 0 && f.format == FormatUint64 {
+		return formatError("out of range of uint64")
+	}
+	return f.validateNumber(float64(n))
+}
+
+// validateBigInteger checks the bounds and format of an integer float64 can't hold exactly.
+func (f *Field) validateBigInteger(n *big.Int) error {
+	switch {
+	case f.format == FormatInt64 && !n.IsInt64():
+		return formatError("out of range of int64")
+	case f.format == FormatUint64 && !n.IsUint64():
+		return formatError("out of range of uint64")
+	}
+	x := new(big.Rat).SetInt(n)
+	if f.max != nil {
+		if c := compare(x, *f.max); c > 0 || f.exclusiveMax && c == 0 {
+			return ErrMaximum
+		}
+	}
+	if f.min != nil {
+		if c := compare(x, *f.min); c < 0 || f.exclusiveMin && c == 0 {
+			return ErrMinimum
+		}
+	}
+	if f.multipleOf != nil && !isMultipleOf(x, *f.multipleOf) {
+		return ErrMultipleOf
+	}
+	return nil
+}
+
+// compare compares x with the limit like big.Rat.Cmp, the limit may be infinite.
+func compare(x *big.Rat, limit float64) int {
+	if math.IsInf(limit, 0) {
+		return -int(math.Copysign(1, limit))
+	}
+	return x.Cmp(new(big.Rat).SetFloat64(limit))
+}
+
+// isMultipleOf divides x by the decimal n is written as, rather than its binary approximation.
+func isMultipleOf(x *big.Rat, n float64) bool {
+	y := decimal(n)
+	if x == nil || y == nil || y.Sign() == 0 {
+		return false
+	}
+	return new(big.Rat).Quo(x, y).IsInt()
+}
+
+// decimal returns the shortest decimal that is the float, e.g. 0.1 rather than its binary approximation.
+func decimal(v float64) *big.Rat {
+	x, ok := new(big.Rat).SetString(strconv.FormatFloat(v, 'g', -1, 64))
+	if !ok {
+		return nil
+	}
+	return x
+}
+
+// plainInteger returns true if the number is written without a fraction or exponent.
+func plainInteger(v json.Number) bool {
+	s := string(v)
+	if len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// integral returns true if the number is an integer, however it is written.
+func integral(v json.Number) bool {
+	if plainInteger(v) {
+		return true
+	}
+	n, err := v.Float64()
+	return err == nil && n == math.Trunc(n)
+}
+
+// numberRat returns the value of a number from Go code or decoded JSON, or false if it isn't one.
+func numberRat(v interface{}) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case uint64:
+		return new(big.Rat).SetUint64(v), true
+	case float64:
+		x := decimal(v)
+		return x, x != nil
+	case json.Number:
+		if plainInteger(v) {
+			return new(big.Rat).SetString(string(v))
+		}
+		n, err := v.Float64()
+		if err != nil {
+			return nil, false
+		}
+		x := decimal(n)
+		return x, x != nil
+	}
+	return nil, false
+}
+
+// canonicalNumbers returns a copy of decoded JSON where numbers equal in value are encoded the
+// same, so 1 and 1.0 are. Integers without a fraction or exponent are kept exactly.
+func canonicalNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = canonicalNumbers(value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = canonicalNumbers(value)
+		}
+		return s
+	case json.Number:
+		if plainInteger(v) {
+			return v
+		}
+		if n, err := v.Float64(); err == nil {
+			return n
+		}
+	}
+	return v
+}
@@ -0,0 +1,109 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestJSONNumbers(t *testing.T) {
+	tests := []struct {
+		Field    Field
+		Input    string
+		Expected error
+	}{
+		{Field: Integer(), Input: `1`},
+		{Field: Integer(), Input: `1.0`},
+		{Field: Integer(), Input: `1e3`},
+		{Field: Integer(), Input: `1.5`, Expected: ErrWrongType},
+		{Field: Integer(), Input: `123456789012345678901234567890`},
+		{Field: Integer().Max(9007199254740992), Input: `9007199254740993`, Expected: ErrMaximum},
+		{Field: Integer().Max(9007199254740992), Input: `9007199254740992`},
+		{Field: Integer().Min(-9007199254740992), Input: `-9007199254740993`, Expected: ErrMinimum},
+		{Field: Integer().MultipleOf(10), Input: `12345678901234567891`, Expected: ErrMultipleOf},
+		{Field: Integer().MultipleOf(10), Input: `12345678901234567890`},
+		{Field: Int64(), Input: `9223372036854775807`},
+		{Field: Int64(), Input: `9223372036854775808`, Expected: ErrFormat},
+		{Field: Int64(), Input: `-9223372036854775809`, Expected: ErrFormat},
+		{Field: Uint64(), Input: `18446744073709551615`},
+		{Field: Uint64(), Input: `18446744073709551616`, Expected: ErrFormat},
+		{Field: Uint64(), Input: `-1`, Expected: ErrFormat},
+		{Field: Number().Max(1.5), Input: `1.6`, Expected: ErrMaximum},
+		{Field: Number(), Input: `1e400`, Expected: ErrWrongType},
+		{Field: String(), Input: `1`, Expected: ErrWrongType},
+		{Field: Integer().Enum(1, 2), Input: `2.0`},
+		{Field: Array().Items(Integer()).UniqueItems(), Input: `[1, 1.0]`, Expected: ErrUniqueItems},
+		{Field: Array().Items(Integer()).UniqueItems(), Input: `[12345678901234567890, 12345678901234567891]`},
+		{Field: Object(map[string]Field{"a": Integer(), "b": String().RequiredIf("a", 1)}), Input: `{"a":1.0}`, Expected: ErrRequired},
+	}
+
+	for _, test := range tests {
+		decoder := json.NewDecoder(strings.NewReader(test.Input))
+		decoder.UseNumber()
+		var input interface{}
+		if err := decoder.Decode(&input); err != nil {
+			t.Fatal(err)
+		}
+		if err := test.Field.Validate(input); !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", test.Input, test.Expected, err)
+		}
+	}
+}
+
+func TestJSONNumbers_Body(t *testing.T) {
+	adapter := NewServeMuxAdapter()
+	router := NewRouter("title", "1.0", adapter)
+	var custom interface{}
+	err := router.Add(Spec{
+		Method: "POST",
+		Path:   "/widgets",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(w, r.Body)
+		},
+		Validate: Validate{
+			Body: Object(map[string]Field{
+				"id":    Int64().Required(),
+				"count": Integer().Custom(func(v interface{}) error { custom = v; return nil }),
+				"price": Number(),
+				"tags":  Array().Items(String()).Default([]string{}),
+			}),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the body is encoded again because of the default, without rounding the numbers
+	body := `{"count":2,"id":1234567890123456789,"price":0.1000000000000000055511151231257827}`
+	w := httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("POST", "/widgets", strings.NewReader(body)))
+	expected := `{"count":2,"id":1234567890123456789,"price":0.1000000000000000055511151231257827,"tags":[]}`
+	if w.Code != http.StatusOK || w.Body.String() != expected {
+		t.Errorf("expected %v got %v %v", expected, w.Code, w.Body.String())
+	}
+	if custom != 2 {
+		t.Errorf("expected Custom to get an int, got %#v", custom)
+	}
+
+	w = httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("POST", "/widgets", strings.NewReader(`{"id":9223372036854775808}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"rule":"format","limit":"int64","value":9223372036854775808`) {
+		t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestJSONNumbers_Query(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+	query := url.Values{"id": {"9223372036854775808"}}
+	if err := r.Validate(Validate{Query: Object(map[string]Field{"id": Uint64()})}, query, nil, nil); err != nil {
+		t.Errorf("expected integers past int64 to be checked exactly, got %v", err)
+	}
+	if err := r.Validate(Validate{Query: Object(map[string]Field{"id": Int64()})}, query, nil, nil); !errors.Is(err, ErrFormat) {
+		t.Errorf("expected %v got %v", ErrFormat, err)
+	}
+}
@@ -0,0 +1,455 @@
+package crud
+
+import (
+	"encoding/json"
+	"github.com/jakecoffman/crud/option"
+	"net/http"
+	"strings"
+)
+
+// OpenAPI is an OpenAPI 3.1 document. The router generates it next to the Swagger 2.0 document
+// and serves it at /openapi.json.
+type OpenAPI struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+type PathItem struct {
+	Get     *OpenAPIOperation `json:"get,omitempty"`
+	Post    *OpenAPIOperation `json:"post,omitempty"`
+	Put     *OpenAPIOperation `json:"put,omitempty"`
+	Delete  *OpenAPIOperation `json:"delete,omitempty"`
+	Patch   *OpenAPIOperation `json:"patch,omitempty"`
+	Options *OpenAPIOperation `json:"options,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	Tags        []string                   `json:"tags,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody               `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type OpenAPIResponse struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Schema is a JSON Schema as used by OpenAPI 3.1.
+type Schema struct {
+	Ref              string             `json:"$ref,omitempty"`
+	Type             SchemaType         `json:"type,omitempty"`
+	Format           string             `json:"format,omitempty"`
+	ContentMediaType string             `json:"contentMediaType,omitempty"`
+	Properties       map[string]*Schema `json:"properties,omitempty"`
+	Items            *Schema            `json:"items,omitempty"`
+	Required         []string           `json:"required,omitempty"`
+	Examples         []interface{}      `json:"examples,omitempty"`
+	Description      string             `json:"description,omitempty"`
+	Minimum          *float64           `json:"minimum,omitempty"`
+	Maximum          *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64           `json:"exclusiveMaximum,omitempty"`
+	MultipleOf       *float64           `json:"multipleOf,omitempty"`
+	MinLength        *int               `json:"minLength,omitempty"`
+	MaxLength        *int               `json:"maxLength,omitempty"`
+	MinItems         *int               `json:"minItems,omitempty"`
+	MaxItems         *int               `json:"maxItems,omitempty"`
+	UniqueItems      bool               `json:"uniqueItems,omitempty"`
+	Enum             []interface{}      `json:"enum,omitempty"`
+	Default          interface{}        `json:"default,omitempty"`
+	Pattern          string             `json:"pattern,omitempty"`
+	OneOf            []*Schema          `json:"oneOf,omitempty"`
+	AnyOf            []*Schema          `json:"anyOf,omitempty"`
+	AllOf            []*Schema          `json:"allOf,omitempty"`
+	Not              *Schema            `json:"not,omitempty"`
+	Discriminator    *Discriminator     `json:"discriminator,omitempty"`
+
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema `json:"propertyNames,omitempty"`
+	MinProperties        *int    `json:"minProperties,omitempty"`
+	MaxProperties        *int    `json:"maxProperties,omitempty"`
+
+	DependentRequired map[string][]string `json:"dependentRequired,omitempty"`
+}
+
+// Discriminator names the property telling which of the oneOf schemas a value is, see Discriminated.
+type Discriminator struct {
+	PropertyName string            `json:"propertyName"`
+	Mapping      map[string]string `json:"mapping,omitempty"`
+}
+
+// SchemaType is the type of a Schema. Nullable schemas have "null" as a second type.
+type SchemaType []string
+
+// MarshalJSON writes a single type as a string and several as an array.
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// openAPIFormats maps the formats of this library to the ones JSON Schema defines.
+var openAPIFormats = map[string]string{
+	FormatDateTime: "date-time",
+}
+
+// components collects the schemas of models while fields are transformed into OpenAPI schemas.
+type components struct {
+	schemas map[string]*Schema
+}
+
+// ref adds the model to the components and returns a schema referencing it. Models with
+// conflicting schemas have already been rejected while building the Swagger definitions.
+func (c *components) ref(f *Field) *Schema {
+	if _, ok := c.schemas[f.name]; !ok {
+		inline := *f
+		inline.name = ""
+		inline.nullable = false
+		c.schemas[f.name] = inline.toSchema(c)
+	}
+	return nullableRef(&Schema{Ref: "#/components/schemas/" + f.name}, f.nullable)
+}
+
+// nullableRef allows null next to the referenced schema.
+func nullableRef(ref *Schema, nullable bool) *Schema {
+	if !nullable {
+		return ref
+	}
+	return &Schema{OneOf: []*Schema{ref, {Type: SchemaType{"null"}}}}
+}
+
+// toSchema transforms a field into an OpenAPI 3.1 schema. Models are added to c and referenced,
+// or inlined when c is nil.
+func (f *Field) toSchema(c *components) *Schema {
+	if f.name != "" && c != nil {
+		return c.ref(f)
+	}
+
+	schema := &Schema{
+		Type:        SchemaType{f.kind},
+		Format:      f.format,
+		Description: f.describe(),
+		Default:     f._default,
+		Enum:        f.enum,
+	}
+	switch f.kind {
+	case KindString:
+		schema.MinLength = intLimit(f.min)
+		schema.MaxLength = intLimit(f.max)
+	case KindArray:
+		schema.MinItems = intLimit(f.min)
+		schema.MaxItems = intLimit(f.max)
+	case KindObject:
+		schema.MinProperties = intLimit(f.min)
+		schema.MaxProperties = intLimit(f.max)
+	default:
+		// in JSON Schema exclusive bounds are numbers of their own
+		if f.exclusiveMin {
+			schema.ExclusiveMinimum = f.min
+		} else {
+			schema.Minimum = f.min
+		}
+		if f.exclusiveMax {
+			schema.ExclusiveMaximum = f.max
+		} else {
+			schema.Maximum = f.max
+		}
+	}
+	schema.MultipleOf = f.multipleOf
+	schema.UniqueItems = f.uniqueItems
+	if format, ok := openAPIFormats[f.format]; ok {
+		schema.Format = format
+	}
+	if example := f.exampleValue(); example != nil {
+		schema.Examples = []interface{}{example}
+	}
+	if f.pattern != nil {
+		schema.Pattern = f.pattern.String()
+	}
+	if f.nullable {
+		schema.Type = append(schema.Type, "null")
+		if schema.Enum != nil {
+			schema.Enum = append(append([]interface{}{}, schema.Enum...), nil)
+		}
+	}
+
+	switch f.kind {
+	case KindArray:
+		if f.arr != nil {
+			schema.Items = f.arr.toSchema(c)
+		}
+	case KindObject:
+		schema.Properties = map[string]*Schema{}
+		for _, name := range sortedKeys(f.obj) {
+			field := f.obj[name]
+			if field.required != nil && *field.required {
+				schema.Required = append(schema.Required, name)
+			}
+			schema.Properties[name] = field.toSchema(c)
+		}
+		if f.values != nil {
+			schema.AdditionalProperties = f.values.toSchema(c)
+		}
+		if f.keyPattern != nil {
+			schema.PropertyNames = &Schema{Pattern: f.keyPattern.String()}
+		}
+		schema.DependentRequired = f.dependentRequired
+	case KindFile:
+		schema.Type = SchemaType{KindString}
+		schema.ContentMediaType = "application/octet-stream"
+		if len(f.mimeTypes) == 1 && !strings.HasSuffix(f.mimeTypes[0], "/*") {
+			schema.ContentMediaType = f.mimeTypes[0]
+		}
+		if f.maxFiles != nil && *f.maxFiles > 1 {
+			schema = &Schema{Type: SchemaType{KindArray}, Items: schema}
+		}
+	case KindOneOf, KindAnyOf, KindAllOf, KindNot:
+		// combined fields have the types of what they combine
+		schema.Type = nil
+		var of []*Schema
+		for i := range f.of {
+			of = append(of, f.of[i].toSchema(c))
+		}
+		switch f.kind {
+		case KindOneOf:
+			schema.OneOf = of
+		case KindAnyOf:
+			schema.AnyOf = of
+		case KindAllOf:
+			schema.AllOf = of
+		case KindNot:
+			schema.Not = of[0]
+		}
+		if f.discriminator != "" {
+			schema.Discriminator = &Discriminator{PropertyName: f.discriminator}
+			// of is in the order of the values
+			for i, value := range sortedKeys(f.variants) {
+				if of[i].Ref != "" {
+					if schema.Discriminator.Mapping == nil {
+						schema.Discriminator.Mapping = map[string]string{}
+					}
+					schema.Discriminator.Mapping[value] = of[i].Ref
+				}
+			}
+		}
+	}
+	return schema
+}
+
+// jsonSchemaToSchema transforms a Swagger 2.0 schema, like those of Spec.Responses, into an OpenAPI 3.1 schema.
+func jsonSchemaToSchema(js JsonSchema) *Schema {
+	schema := &Schema{
+		Ref:         strings.Replace(js.Ref, "#/definitions/", "#/components/schemas/", 1),
+		Format:      js.Format,
+		Required:    js.Required,
+		Description: js.Description,
+		Enum:        js.Enum,
+		Default:     js.Default,
+		Pattern:     js.Pattern,
+	}
+	if js.Type != "" {
+		schema.Type = SchemaType{js.Type}
+		if js.XNullable {
+			schema.Type = append(schema.Type, "null")
+		}
+	}
+	if format, ok := openAPIFormats[js.Format]; ok {
+		schema.Format = format
+	}
+	if js.Example != nil {
+		schema.Examples = []interface{}{js.Example}
+	}
+	schema.Minimum = js.Minimum
+	if js.ExclusiveMinimum {
+		schema.Minimum, schema.ExclusiveMinimum = nil, js.Minimum
+	}
+	schema.Maximum = js.Maximum
+	if js.ExclusiveMaximum {
+		schema.Maximum, schema.ExclusiveMaximum = nil, js.Maximum
+	}
+	if js.MultipleOf != 0 {
+		schema.MultipleOf = &js.MultipleOf
+	}
+	schema.UniqueItems = js.UniqueItems
+	if js.Items != nil {
+		schema.Items = jsonSchemaToSchema(*js.Items)
+	}
+	if js.Properties != nil {
+		schema.Properties = map[string]*Schema{}
+		for name, property := range js.Properties {
+			schema.Properties[name] = jsonSchemaToSchema(property)
+		}
+	}
+	for _, of := range js.OneOf {
+		schema.OneOf = append(schema.OneOf, jsonSchemaToSchema(of))
+	}
+	for _, of := range js.AnyOf {
+		schema.AnyOf = append(schema.AnyOf, jsonSchemaToSchema(of))
+	}
+	for _, of := range js.AllOf {
+		schema.AllOf = append(schema.AllOf, jsonSchemaToSchema(of))
+	}
+	if js.Not != nil {
+		schema.Not = jsonSchemaToSchema(*js.Not)
+	}
+	if js.AdditionalProperties != nil {
+		schema.AdditionalProperties = jsonSchemaToSchema(*js.AdditionalProperties)
+	}
+	schema.MinProperties = js.MinProperties
+	schema.MaxProperties = js.MaxProperties
+	schema.MinLength = js.MinLength
+	schema.MaxLength = js.MaxLength
+	schema.MinItems = js.MinItems
+	schema.MaxItems = js.MaxItems
+	if js.Discriminator != "" {
+		schema.Discriminator = &Discriminator{PropertyName: js.Discriminator}
+	}
+	if schema.Ref != "" {
+		return nullableRef(schema, js.XNullable)
+	}
+	return schema
+}
+
+// addOpenAPI adds the spec to the OpenAPI document. The Swagger operation has already been
+// built and is used for the parts that are computed there, like the responses.
+func (r *Router) addOpenAPI(spec *Spec, operation *Operation) {
+	c := &components{schemas: r.OpenAPI.Components.Schemas}
+	op := &OpenAPIOperation{
+		Tags:        spec.Tags,
+		Summary:     spec.Summary,
+		Description: spec.Description,
+		Responses:   map[string]OpenAPIResponse{},
+	}
+
+	params := []struct {
+		in    string
+		field Field
+	}{
+		{InPath, spec.Validate.Path},
+		{InQuery, spec.Validate.Query},
+		{InHeader, spec.Validate.Header},
+		{InCookie, spec.Validate.Cookie},
+	}
+	for _, p := range params {
+		for _, name := range sortedKeys(p.field.obj) {
+			field := p.field.obj[name]
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:        name,
+				In:          p.in,
+				Description: field.description,
+				// path parameters are always required in OpenAPI
+				Required: p.in == InPath || (field.required != nil && *field.required),
+				Schema:   field.toSchema(nil),
+			})
+		}
+	}
+
+	if spec.Validate.Body.Initialized() {
+		// use the definition name picked for Swagger so both documents agree
+		schema := spec.Validate.Body.toSchema(c)
+		var description string
+		for _, param := range operation.Parameters {
+			if param.In != "body" {
+				continue
+			}
+			description = param.Description
+			if schema.Ref == "" {
+				name := strings.TrimPrefix(param.Schema.Ref, "#/definitions/")
+				c.schemas[name] = schema
+				schema = &Schema{Ref: "#/components/schemas/" + name}
+			}
+		}
+		op.RequestBody = &RequestBody{
+			Description: description,
+			Required:    true,
+			Content:     map[string]MediaType{"application/json": {Schema: schema}},
+		}
+	}
+	if spec.Validate.FormData.Initialized() {
+		schema := spec.Validate.FormData.toSchema(nil)
+		op.RequestBody = &RequestBody{
+			Required: len(schema.Required) > 0,
+			Content:  map[string]MediaType{operation.Consumes[0]: {Schema: schema}},
+		}
+	}
+
+	for code, response := range operation.Responses {
+		res := OpenAPIResponse{Description: response.Description}
+		if response.Schema.Type != "" || response.Schema.Ref != "" {
+			res.Content = map[string]MediaType{"application/json": {Schema: jsonSchemaToSchema(response.Schema)}}
+		}
+		op.Responses[code] = res
+	}
+
+	if _, ok := r.OpenAPI.Paths[spec.Path]; !ok {
+		r.OpenAPI.Paths[spec.Path] = &PathItem{}
+	}
+	path := r.OpenAPI.Paths[spec.Path]
+	// duplicates have already been rejected while building the Swagger operation
+	switch strings.ToLower(spec.Method) {
+	case "get":
+		path.Get = op
+	case "post":
+		path.Post = op
+	case "put":
+		path.Put = op
+	case "patch":
+		path.Patch = op
+	case "options":
+		path.Options = op
+	case "delete":
+		path.Delete = op
+	}
+}
+
+// openAPISpec returns the route serving the OpenAPI document. It's installed by Serve rather than
+// added, so it isn't documented itself.
+func (r *Router) openAPISpec() *Spec {
+	return &Spec{
+		Method: "GET",
+		Path:   "/openapi.json",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			doc := r.OpenAPI
+			if len(doc.Servers) == 0 && r.Swagger.BasePath != "" {
+				doc.Servers = []Server{{URL: r.Swagger.BasePath}}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(doc)
+		}),
+		Options: []option.Option{option.ValidateResponses(0)},
+	}
+}
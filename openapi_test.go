@@ -0,0 +1,117 @@
+package crud
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	adapter := NewServeMuxAdapter()
+	r := NewRouter("widgets", "1.0", adapter)
+	r.Swagger.BasePath = "/api"
+
+	type gadget struct {
+		Name  string  `json:"name"`
+		Owner *string `json:"owner"`
+	}
+	widget := Model("Widget", Object(map[string]Field{
+		"name":    String().Required(),
+		"created": DateTime(),
+	}))
+
+	err := r.Add(Spec{
+		Method:  "POST",
+		Path:    "/widgets/{id}",
+		Handler: func(w http.ResponseWriter, r *http.Request) {},
+		Validate: Validate{
+			Path:   Object(map[string]Field{"id": Integer()}),
+			Query:  Object(map[string]Field{"tags": Array().Items(String())}),
+			Header: Object(map[string]Field{"X-Trace": String().Required()}),
+			Cookie: Object(map[string]Field{"session": String().Description("session id")}),
+			Body:   widget,
+		},
+		Responses: map[string]Response{
+			"200": {Description: "OK", Schema: JsonSchema{Ref: "#/definitions/Widget"}},
+		},
+	}, Spec{
+		Method:   "PUT",
+		Path:     "/gadgets",
+		Handler:  func(w http.ResponseWriter, r *http.Request) {},
+		Validate: Validate{Body: FromStruct(gadget{})},
+	}, Spec{
+		Method:  "POST",
+		Path:    "/uploads",
+		Handler: func(w http.ResponseWriter, r *http.Request) {},
+		Validate: Validate{FormData: Object(map[string]Field{
+			"name":   String().Required(),
+			"images": File().MimeTypes("image/png").MaxFiles(3),
+		})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = adapter.Install(r, r.openAPISpec()); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatal(w.Code, w.Body.String())
+	}
+
+	var doc map[string]interface{}
+	if err = json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path ...interface{}) interface{} {
+		var v interface{} = doc
+		for _, p := range path {
+			switch p := p.(type) {
+			case string:
+				v = v.(map[string]interface{})[p]
+			case int:
+				v = v.([]interface{})[p]
+			}
+		}
+		return v
+	}
+
+	expect := func(expected interface{}, path ...interface{}) {
+		t.Helper()
+		if got := get(path...); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%v: expected %v got %v", path, expected, got)
+		}
+	}
+
+	expect("3.1.0", "openapi")
+	expect("/api", "servers", 0, "url")
+	post := []interface{}{"paths", "/widgets/{id}", "post"}
+	param := func(i int, key string) []interface{} {
+		return append(append([]interface{}{}, post...), "parameters", i, key)
+	}
+	expect("path", param(0, "in")...)
+	expect(true, param(0, "required")...)
+	expect("query", param(1, "in")...)
+	expect("array", append(param(1, "schema"), "type")...)
+	expect("header", param(2, "in")...)
+	expect("cookie", param(3, "in")...)
+	expect("session id", param(3, "description")...)
+	expect("#/components/schemas/Widget", append(post, "requestBody", "content", "application/json", "schema", "$ref")...)
+	expect("#/components/schemas/Widget", append(post, "responses", "200", "content", "application/json", "schema", "$ref")...)
+	expect("date-time", "components", "schemas", "Widget", "properties", "created", "format")
+	expect([]interface{}{"name"}, "components", "schemas", "Widget", "required")
+
+	// anonymous bodies get the same name as in the swagger
+	ref := r.Swagger.Paths["/gadgets"].Put.Parameters[0].Schema.Ref[len("#/definitions/"):]
+	expect([]interface{}{"string", "null"}, "components", "schemas", ref, "properties", "owner", "type")
+	expect("string", "components", "schemas", ref, "properties", "name", "type")
+
+	form := []interface{}{"paths", "/uploads", "post", "requestBody", "content", "multipart/form-data", "schema"}
+	expect("array", append(form, "properties", "images", "type")...)
+	expect("image/png", append(form, "properties", "images", "items", "contentMediaType")...)
+	expect([]interface{}{"name"}, append(form, "required")...)
+}
@@ -1,9 +1,23 @@
 package option
 
+import "net/http"
+
 // Option configures a router option. Use the convenience constructors below.
 type Option struct {
-	StripUnknown *bool
-	AllowUnknown *bool
+	StripUnknown  *bool
+	AllowUnknown  *bool
+	CollectErrors *int
+	// ErrorRenderer holds a crud.ErrorRenderer, use crud.ErrorRendererOption to set it.
+	ErrorRenderer interface{}
+
+	ResponseSampleRate *float64
+	OnResponseError    func(r *http.Request, err error)
+
+	MaxBodyBytes    *int64
+	MaxDepth        *int
+	MaxElements     *int
+	MaxStringLength *int
+	StrictJSON      *bool
 }
 
 // StripUnknown will remove unknown fields if true, leave them if false. Defaults to true.
@@ -15,3 +29,52 @@ func StripUnknown(v bool) Option {
 func AllowUnknown(v bool) Option {
 	return Option{AllowUnknown: &v}
 }
+
+// CollectErrors makes validation keep going after the first failure, returning up to limit
+// errors together. A limit of 1 or less stops at the first error, which is the default.
+func CollectErrors(limit int) Option {
+	return Option{CollectErrors: &limit}
+}
+
+// ValidateResponses checks handler responses against Spec.Responses for the given fraction of
+// requests, from 0 (off, the default) to 1 (every request). Checked responses are buffered in full
+// and sent once the handler returns, so they aren't streamed or flushed early.
+func ValidateResponses(sampleRate float64) Option {
+	return Option{ResponseSampleRate: &sampleRate}
+}
+
+// OnResponseError is called when a response fails validation. Without it the response is
+// replaced with a 500 Internal Server Error.
+func OnResponseError(handler func(r *http.Request, err error)) Option {
+	return Option{OnResponseError: handler}
+}
+
+// MaxBodyBytes limits the size of JSON bodies, larger ones are rejected with 413 Request Entity
+// Too Large before they are decoded. 0 is no limit, which is the default.
+func MaxBodyBytes(n int64) Option {
+	return Option{MaxBodyBytes: &n}
+}
+
+// MaxDepth limits how deeply the objects and arrays of JSON bodies can be nested, 1 allows an
+// object or array holding no others. 0 is no limit, which is the default.
+func MaxDepth(n int) Option {
+	return Option{MaxDepth: &n}
+}
+
+// MaxElements limits the number of object properties and array items of JSON bodies, counted
+// together at every depth. 0 is no limit, which is the default.
+func MaxElements(n int) Option {
+	return Option{MaxElements: &n}
+}
+
+// StrictJSON rejects JSON bodies with duplicate keys, data after the body or strings that aren't
+// valid UTF-8, which are otherwise accepted like encoding/json does. Defaults to false.
+func StrictJSON(v bool) Option {
+	return Option{StrictJSON: &v}
+}
+
+// MaxStringLength limits the length of the strings and keys of JSON bodies, in characters like
+// Min and Max count them. 0 is no limit, which is the default.
+func MaxStringLength(n int) Option {
+	return Option{MaxStringLength: &n}
+}
@@ -1,126 +1,268 @@
 package crud
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/jakecoffman/crud/option"
+	"mime/multipart"
+	"net/http"
 	"net/url"
 	"strconv"
 )
 
+// Inputs holds the parts of a request that are validated. Adapters fill in what the
+// router they wrap provides, anything left nil is skipped.
+type Inputs struct {
+	Path   map[string]string
+	Query  url.Values
+	Header http.Header
+	Body   interface{}
+	// BodyChanged is set by ValidateInputs when stripping or defaulting changed Body, so
+	// adapters only encode it again when they have to.
+	BodyChanged bool
+	Form        url.Values
+	Files       map[string][]*multipart.FileHeader
+}
+
+type inputsKey struct{}
+
+// ContextWithInputs returns a copy of ctx holding the validated inputs. Adapters call this
+// after validation so handlers can get to them with InputsFromContext.
+func ContextWithInputs(ctx context.Context, in *Inputs) context.Context {
+	return context.WithValue(ctx, inputsKey{}, in)
+}
+
+// InputsFromContext returns the validated inputs of the request, or nil if there are none.
+func InputsFromContext(ctx context.Context) *Inputs {
+	in, _ := ctx.Value(inputsKey{}).(*Inputs)
+	return in
+}
+
 // Validate checks the spec against the inputs and returns an error if it finds one.
 func (r *Router) Validate(val Validate, query url.Values, body interface{}, path map[string]string) error {
-	if val.Query.kind == KindObject { // not sure how any other type makes sense
+	return r.ValidateInputs(val, &Inputs{Path: path, Query: query, Body: body})
+}
+
+// ValidateInputs checks the spec against all inputs of a request and returns an error if it finds one.
+// Query, Header and Form are modified in place when values are stripped or defaulted. The options
+// override the router's options, adapters pass Spec.Options here.
+func (r *Router) ValidateInputs(val Validate, in *Inputs, options ...option.Option) error {
+	s := r.settings.apply(options...)
+	c := &collector{limit: s.collectErrors}
+
+	v := val.compiled
+	if v == nil || v.stripUnknown != s.stripUnknown || v.allowUnknown != s.allowUnknown {
+		// not added with Router.Add, or validated with other options than it was added with
+		v = compile(val, s)
+	}
 
-		// reject unknown values
-		if (val.Query.unknown == nil && r.allowUnknown == false) || !val.Query.isAllowUnknown() {
-			for key := range query {
-				if _, ok := val.Query.obj[key]; !ok {
-					return fmt.Errorf("unexpected query parameter %s: %w", key, errUnknown)
+	if v.path.kind == KindObject {
+		for _, field := range sortedKeys(v.path.obj) {
+			schema := v.path.obj[field]
+			param := in.Path[field]
+
+			convertedValue, err := convert(param, schema)
+			if err == nil {
+				err = schema.Validate(convertedValue)
+			}
+			if err != nil {
+				if err = c.add(newValidationError(InPath, joinPointer("", field), &schema, param, err)); err != nil {
+					return err
 				}
 			}
 		}
+	}
 
-		// strip unknown values
-		if (val.Query.strip == nil && r.stripUnknown == true) || val.Query.isStripUnknown() {
-			for key := range query {
-				if _, ok := val.Query.obj[key]; !ok {
-					delete(query, key)
-				}
-			}
+	if v.query.kind == KindObject { // not sure how any other type makes sense
+		if err := validateValues(InQuery, v.query, in.Query, identity, v.queryAllowUnknown, v.queryStripUnknown, c); err != nil {
+			return err
 		}
+	}
 
-		for field, schema := range val.Query.obj {
-			// query values are always strings, so we must try to convert
-			queryValue := query[field]
+	if v.header.kind == KindObject {
+		header := in.Header
+		if header == nil {
+			header = http.Header{}
+		}
+		if err := validateValues(InHeader, v.header, header, http.CanonicalHeaderKey, v.headerAllowUnknown, v.headerStripUnknown, c); err != nil {
+			return err
+		}
+	}
 
-			if len(queryValue) == 0 {
-				if schema.required != nil && *schema.required {
-					return fmt.Errorf("query validation failed for field %v: %w", field, errRequired)
-				}
-				if schema._default != nil {
-					query[field] = []string{fmt.Sprint(schema._default)}
-				}
-				continue
-			}
-			if len(queryValue) > 1 {
-				if schema.kind != KindArray {
-					return fmt.Errorf("query validation failed for field %v: %w", field, errWrongType)
-				}
-			}
-			if schema.kind == KindArray {
-				if schema.min != nil && float64(len(queryValue)) < *schema.min {
-					return errMinimum
-				}
-				if schema.max != nil && float64(len(queryValue)) > *schema.max {
-					return errMaximum
-				}
-				// sadly we have to convert to a []interface{} to simplify the validation code
-				var intray []interface{}
-				for _, v := range queryValue {
-					intray = append(intray, v)
-				}
-				if schema.arr != nil {
-					for _, v := range queryValue {
-						convertedValue, err := convert(v, *schema.arr)
-						if err != nil {
-							return fmt.Errorf("query validation failed for field %v: %w", field, err)
-						}
-						if err = schema.arr.Validate(convertedValue); err != nil {
-							return fmt.Errorf("query validation failed for field %v: %w", field, err)
-						}
-					}
-				}
-			} else {
-				convertedValue, err := convert(queryValue[0], schema)
-				if err != nil {
-					return fmt.Errorf("query validation failed for field %v: %w", field, err)
-				}
-				if err = schema.Validate(convertedValue); err != nil {
-					return fmt.Errorf("query validation failed for field %v: %w", field, err)
+	if v.cookie.kind == KindObject {
+		// like headers the router defaults are not used, and cookies are never stripped
+		cookies := url.Values{}
+		for _, cookie := range (&http.Request{Header: in.Header}).Cookies() {
+			cookies.Add(cookie.Name, cookie.Value)
+		}
+		if err := validateValues(InCookie, v.cookie, cookies, identity, v.cookie.isAllowUnknown(), false, c); err != nil {
+			return err
+		}
+	}
+
+	if v.form.kind == KindObject {
+		form := in.Form
+		if form == nil {
+			form = url.Values{}
+		}
+		if err := validateValues(InForm, v.form, form, identity, v.formAllowUnknown, v.formStripUnknown, c); err != nil {
+			return err
+		}
+		if err := validateFiles(v.form, in.Files, v.formAllowUnknown, v.formStripUnknown, c); err != nil {
+			return err
+		}
+	}
+
+	if v.body.Initialized() && v.body.kind != KindFile {
+		body := v.body
+		err := validateObject(nil, &body, in.Body, c)
+		in.BodyChanged = c.changed
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.err()
+}
+
+func identity(s string) string {
+	return s
+}
+
+// validateValues validates inputs that arrive as lists of strings, like query parameters and headers.
+// The key function maps the field names in the schema to the keys used in values.
+func validateValues(in string, spec Field, values map[string][]string, key func(string) string, allowUnknown, stripUnknown bool, c *collector) error {
+	known := map[string]struct{}{}
+	for field := range spec.obj {
+		known[key(field)] = struct{}{}
+	}
+
+	// reject unknown values
+	if !allowUnknown {
+		for _, k := range sortedKeys(values) {
+			if _, ok := known[k]; !ok {
+				if err := c.add(newValidationError(in, joinPointer("", k), &spec, values[k], ErrUnknown)); err != nil {
+					return err
 				}
 			}
 		}
 	}
 
-	if val.Body.Initialized() && val.Body.kind != KindFile {
-		// use router defaults if the object doesn't have anything set
-		f := val.Body
-		if f.strip == nil {
-			f = f.Strip(r.stripUnknown)
+	// strip unknown values
+	if stripUnknown {
+		for k := range values {
+			if _, ok := known[k]; !ok {
+				delete(values, k)
+			}
 		}
-		if f.unknown == nil {
-			f = f.Unknown(r.allowUnknown)
+	}
+
+	for _, field := range sortedKeys(spec.obj) {
+		schema := spec.obj[field]
+		if schema.kind == KindFile {
+			// files are not sent as values, see validateFiles
+			continue
 		}
-		// ensure Required() since it's confusing and error-prone otherwise
-		f = f.Required()
-		if err := f.Validate(body); err != nil {
-			return err
+		if err := validateValue(in, field, schema, values, key(field)); err != nil {
+			if err = c.add(err); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
 
-	if val.Path.kind == KindObject {
-		for field, schema := range val.Path.obj {
-			param := path[field]
+// validateValue validates a single field of validateValues.
+func validateValue(in, field string, schema Field, values map[string][]string, key string) error {
+	// these values are always strings, so we must try to convert
+	value := values[key]
 
-			convertedValue, err := convert(param, schema)
-			if err != nil {
-				return fmt.Errorf("path validation failed for field %v: %w", field, err)
+	if len(value) == 0 {
+		if schema.required != nil && *schema.required {
+			return newValidationError(in, joinPointer("", field), &schema, nil, ErrRequired)
+		}
+		if items, ok := schema._default.([]interface{}); ok {
+			// arrays are sent as repeated values
+			values[key] = make([]string, len(items))
+			for i, item := range items {
+				values[key][i] = formatValue(item)
 			}
-			if err = schema.Validate(convertedValue); err != nil {
-				return fmt.Errorf("path validation failed for field %v: %w", field, err)
+		} else if schema._default != nil {
+			values[key] = []string{formatValue(schema._default)}
+		}
+		return nil
+	}
+	if len(value) > 1 {
+		if schema.kind != KindArray {
+			return newValidationError(in, joinPointer("", field), &schema, value, ErrWrongType)
+		}
+	}
+	if schema.kind == KindArray {
+		if schema.min != nil && float64(len(value)) < *schema.min {
+			return newValidationError(in, joinPointer("", field), &schema, value, ErrMinimum)
+		}
+		if schema.max != nil && float64(len(value)) > *schema.max {
+			return newValidationError(in, joinPointer("", field), &schema, value, ErrMaximum)
+		}
+		items := make([]interface{}, len(value))
+		for i, v := range value {
+			items[i] = v
+		}
+		if schema.arr != nil {
+			for i, v := range value {
+				convertedValue, err := convert(v, *schema.arr)
+				if err == nil {
+					err = schema.arr.Validate(convertedValue)
+				}
+				if err != nil {
+					return newValidationError(in, joinPointer(joinPointer("", field), i), schema.arr, v, err)
+				}
+				items[i] = convertedValue
 			}
 		}
+		if schema.uniqueItems && !uniqueItems(items) {
+			return newValidationError(in, joinPointer("", field), &schema, value, ErrUniqueItems)
+		}
+		return nil
 	}
 
+	convertedValue, err := convert(value[0], schema)
+	if err == nil {
+		err = schema.Validate(convertedValue)
+	}
+	if err != nil {
+		return newValidationError(in, joinPointer("", field), &schema, value[0], err)
+	}
 	return nil
 }
 
+// formatValue writes a default the way it is sent as a query, header or cookie value, so convert
+// reads it back. Objects are rejected by Spec.Valid.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
+
 // For certain types of data passed like Query and Header, the value is always
 // a string. So this function attempts to convert the string into the desired field kind.
 func convert(inputValue string, schema Field) (interface{}, error) {
 	// don't try to convert if the field is empty
 	if inputValue == "" {
 		if schema.required != nil && *schema.required {
-			return nil, errRequired
+			return nil, ErrRequired
 		}
 		return nil, nil
 	}
@@ -132,7 +274,7 @@ func convert(inputValue string, schema Field) (interface{}, error) {
 		} else if inputValue == "false" {
 			convertedValue = false
 		} else {
-			return nil, errWrongType
+			return nil, ErrWrongType
 		}
 	case KindString:
 		convertedValue = inputValue
@@ -140,13 +282,17 @@ func convert(inputValue string, schema Field) (interface{}, error) {
 		var err error
 		convertedValue, err = strconv.ParseFloat(inputValue, 64)
 		if err != nil {
-			return nil, errWrongType
+			return nil, ErrWrongType
 		}
 	case KindInteger:
 		var err error
 		convertedValue, err = strconv.Atoi(inputValue)
+		if errors.Is(err, strconv.ErrRange) {
+			// validated exactly, like integers in bodies
+			convertedValue, err = json.Number(inputValue), nil
+		}
 		if err != nil {
-			return nil, errWrongType
+			return nil, ErrWrongType
 		}
 	default:
 		return nil, fmt.Errorf("unknown kind: %v", schema.kind)
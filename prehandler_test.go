@@ -3,8 +3,11 @@ package crud
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/jakecoffman/crud/option"
+	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -37,13 +40,13 @@ func TestQueryValidation(t *testing.T) {
 				"testquery": String().Required(),
 			},
 			Input:    "",
-			Expected: errRequired,
+			Expected: ErrRequired,
 		}, {
 			Schema: map[string]Field{
 				"testquery": String().Required(),
 			},
 			Input:    "testquery=",
-			Expected: errRequired,
+			Expected: ErrRequired,
 		}, {
 			Schema: map[string]Field{
 				"testquery": String().Required(),
@@ -61,7 +64,7 @@ func TestQueryValidation(t *testing.T) {
 				"testquery": Number().Required(),
 			},
 			Input:    "",
-			Expected: errRequired,
+			Expected: ErrRequired,
 		},
 		{
 			Schema: map[string]Field{
@@ -82,7 +85,7 @@ func TestQueryValidation(t *testing.T) {
 				"testquery": Number(),
 			},
 			Input:    "testquery=a",
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Schema: map[string]Field{
@@ -103,7 +106,7 @@ func TestQueryValidation(t *testing.T) {
 				"testquery": Boolean(),
 			},
 			Input:    "testquery=1",
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Schema: map[string]Field{
@@ -117,28 +120,28 @@ func TestQueryValidation(t *testing.T) {
 				"testquery": Integer().Max(1),
 			},
 			Input:    "testquery=2",
-			Expected: errMaximum,
+			Expected: ErrMaximum,
 		},
 		{
 			Schema: map[string]Field{
 				"testquery": Integer().Min(5),
 			},
 			Input:    "testquery=4",
-			Expected: errMinimum,
+			Expected: ErrMinimum,
 		},
 		{
 			Schema: map[string]Field{
 				"testquery": Integer(),
 			},
 			Input:    "testquery=1.1",
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Schema: map[string]Field{
 				"testquery": Integer(),
 			},
 			Input:    "testquery=a",
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Schema: map[string]Field{
@@ -152,7 +155,7 @@ func TestQueryValidation(t *testing.T) {
 				"testquery": Integer().Enum(1, 2),
 			},
 			Input:    "testquery=3",
-			Expected: errEnumNotFound,
+			Expected: ErrEnumNotFound,
 		},
 		{
 			Schema: map[string]Field{
@@ -166,7 +169,7 @@ func TestQueryValidation(t *testing.T) {
 				"testquery": String().Enum("a"),
 			},
 			Input:    "testquery=b",
-			Expected: errEnumNotFound,
+			Expected: ErrEnumNotFound,
 		},
 		{
 			Schema: map[string]Field{
@@ -187,14 +190,14 @@ func TestQueryValidation(t *testing.T) {
 				"testquery": Array().Items(Number()),
 			},
 			Input:    "testquery=1&testquery=a",
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Schema: map[string]Field{
 				"testquery": Array().Min(2),
 			},
 			Input:    "testquery=z",
-			Expected: errMinimum,
+			Expected: ErrMinimum,
 		},
 		{
 			Schema: map[string]Field{
@@ -249,6 +252,15 @@ func TestQueryDefaults(t *testing.T) {
 			Input:    "",
 			Expected: "q1=1&q2=2",
 		},
+		{
+			// arrays are sent as repeated values, numbers as they are written
+			Schema: map[string]Field{
+				"big": Number().Default(1e21),
+				"ids": Array().Items(Number()).Default([]float64{1.5, 2}),
+			},
+			Input:    "",
+			Expected: "big=1000000000000000000000&ids=1.5&ids=2",
+		},
 	}
 
 	for i, test := range tests {
@@ -284,12 +296,12 @@ func TestSimpleBodyValidation(t *testing.T) {
 		{
 			Schema:   Number(),
 			Input:    1,
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Schema:   Number(),
 			Input:    "a",
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Schema:   String(),
@@ -309,7 +321,7 @@ func TestSimpleBodyValidation(t *testing.T) {
 		{
 			Schema:   Boolean(),
 			Input:    `1`,
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 	}
 
@@ -342,7 +354,7 @@ func TestBodyValidation(t *testing.T) {
 				"int": Integer().Required(),
 			},
 			Input:    `{}`,
-			Expected: errRequired,
+			Expected: ErrRequired,
 		},
 		{
 			Schema: map[string]Field{
@@ -356,7 +368,7 @@ func TestBodyValidation(t *testing.T) {
 				"int": Integer().Required(),
 			},
 			Input:    `{"int":1.9}`,
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Schema: map[string]Field{
@@ -386,7 +398,7 @@ func TestBodyValidation(t *testing.T) {
 				}),
 			},
 			Input:    `{"obj2":{"inner":"not a number"}}`,
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		}, {
 			Schema: map[string]Field{
 				"arr1": Array(),
@@ -404,13 +416,13 @@ func TestBodyValidation(t *testing.T) {
 				"arr2": Array().Items(Number()),
 			},
 			Input:    `{"arr2":["a"]}`,
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		}, {
 			Schema: map[string]Field{
 				"arr3": Array().Min(2),
 			},
 			Input:    `{"arr3":["a"]}`,
-			Expected: errMinimum,
+			Expected: ErrMinimum,
 		}, {
 			Schema: map[string]Field{
 				"complex1": Object(map[string]Field{
@@ -430,7 +442,7 @@ func TestBodyValidation(t *testing.T) {
 				}).Required(),
 			},
 			Input:    `{"complex2":{"array":[{"id":"a"}]}}`,
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 	}
 
@@ -528,8 +540,8 @@ func TestBodyErrorUnknown(t *testing.T) {
 
 		err := r.Validate(Validate{Body: Object(test.Schema)}, nil, input, nil)
 
-		if !errors.Is(err, errUnknown) {
-			t.Errorf("%v: expected '%v' got '%v'", i, errUnknown, err)
+		if !errors.Is(err, ErrUnknown) {
+			t.Errorf("%v: expected '%v' got '%v'", i, ErrUnknown, err)
 			continue
 		}
 	}
@@ -555,14 +567,14 @@ func TestPathValidation(t *testing.T) {
 				"int": Integer().Required(),
 			},
 			Input:    ``,
-			Expected: errRequired,
+			Expected: ErrRequired,
 		},
 		{
 			Schema: map[string]Field{
 				"id": Integer().Required(),
 			},
 			Input:    `a`,
-			Expected: errWrongType,
+			Expected: ErrWrongType,
 		},
 		{
 			Schema: map[string]Field{
@@ -646,8 +658,8 @@ func TestUnknown_Query(t *testing.T) {
 	spec := Object(map[string]Field{})
 
 	err := r.Validate(Validate{Query: spec}, query, nil, nil)
-	if !errors.Is(err, errUnknown) {
-		t.Errorf("Expected '%s' but got '%s'", errUnknown, err)
+	if !errors.Is(err, ErrUnknown) {
+		t.Errorf("Expected '%s' but got '%s'", ErrUnknown, err)
 	}
 
 	spec = spec.Unknown(true)
@@ -682,12 +694,199 @@ func TestStrip_Query(t *testing.T) {
 	}
 }
 
+func TestStrip_QueryRouterDefault(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{}, option.StripUnknown(false))
+	spec := Object(map[string]Field{})
+
+	// the router's setting is used when the spec has none
+	query := url.Values{"unknown": []string{"value"}}
+	if err := r.Validate(Validate{Query: spec}, query, nil, nil); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if _, ok := query["unknown"]; !ok {
+		t.Error("Expected the value to not have been stripped")
+	}
+
+	// and the spec's setting over it
+	if err := r.Validate(Validate{Query: spec.Strip(true)}, query, nil, nil); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if _, ok := query["unknown"]; ok {
+		t.Error("Expected the value to have been stripped")
+	}
+
+	form := url.Values{"unknown": []string{"value"}}
+	if err := r.ValidateInputs(Validate{FormData: spec}, &Inputs{Form: form}); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if _, ok := form["unknown"]; !ok {
+		t.Error("Expected the form value to not have been stripped")
+	}
+}
+
 func Test_BodyValidateRequiredAutomatically(t *testing.T) {
 	r := NewRouter("", "", &TestAdapter{}, option.AllowUnknown(false))
 
 	err := r.Validate(Validate{Body: Object(map[string]Field{})}, nil, nil, nil)
 
-	if !errors.Is(err, errRequired) {
-		t.Error("Expected errRequired got", err)
+	if !errors.Is(err, ErrRequired) {
+		t.Error("Expected ErrRequired got", err)
+	}
+}
+
+func TestHeaderValidation(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{}, option.AllowUnknown(false))
+
+	tests := []struct {
+		Schema   Field
+		Input    http.Header
+		Expected error
+	}{
+		{
+			Schema:   Object(map[string]Field{"X-Token": String().Required()}),
+			Input:    http.Header{"User-Agent": []string{"test"}},
+			Expected: ErrRequired,
+		},
+		{
+			Schema:   Object(map[string]Field{"x-token": String().Required()}),
+			Input:    http.Header{"X-Token": []string{"abc"}, "User-Agent": []string{"test"}},
+			Expected: nil,
+		},
+		{
+			Schema:   Object(map[string]Field{"X-Count": Integer().Max(5)}),
+			Input:    http.Header{"X-Count": []string{"6"}},
+			Expected: ErrMaximum,
+		},
+		{
+			Schema:   Object(map[string]Field{"X-Count": Integer()}),
+			Input:    http.Header{"X-Count": []string{"a"}},
+			Expected: ErrWrongType,
+		},
+		{
+			Schema:   Object(map[string]Field{"X-Mode": String().Enum("a", "b")}),
+			Input:    http.Header{"X-Mode": []string{"c"}},
+			Expected: ErrEnumNotFound,
+		},
+		{
+			Schema:   Object(map[string]Field{"X-Mode": String().Pattern("^[a-z]+$")}),
+			Input:    http.Header{"X-Mode": []string{"ABC"}},
+			Expected: ErrPattern,
+		},
+		{
+			Schema:   Object(map[string]Field{"X-Mode": String()}).Unknown(false),
+			Input:    http.Header{"User-Agent": []string{"test"}},
+			Expected: ErrUnknown,
+		},
+	}
+
+	for i, test := range tests {
+		err := r.ValidateInputs(Validate{Header: test.Schema}, &Inputs{Header: test.Input})
+
+		if !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected '%v' got '%v'. input: '%v'. schema: '%v'", i, test.Expected, err, test.Input, test.Schema)
+		}
+	}
+}
+
+func TestHeaderDefaultsAndStrip(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+
+	header := http.Header{"User-Agent": []string{"test"}}
+	spec := Object(map[string]Field{
+		"x-page-size": Integer().Default(10),
+	})
+
+	if err := r.ValidateInputs(Validate{Header: spec}, &Inputs{Header: header}); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("X-Page-Size") != "10" {
+		t.Errorf("expected default to be set, got %v", header)
+	}
+	if header.Get("User-Agent") != "test" {
+		t.Errorf("expected unknown headers to be kept by default, got %v", header)
+	}
+
+	if err := r.ValidateInputs(Validate{Header: spec.Strip(true)}, &Inputs{Header: header}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := header["User-Agent"]; ok {
+		t.Errorf("expected unknown header to be stripped, got %v", header)
+	}
+}
+
+func TestCookieValidation(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+
+	spec := Object(map[string]Field{
+		"session": String().Required().Pattern("^[a-f0-9]+$"),
+		"theme":   String().Enum("light", "dark"),
+	})
+
+	tests := []struct {
+		Cookie   string
+		Expected error
+	}{
+		{Cookie: "", Expected: ErrRequired},
+		{Cookie: "session=abc123", Expected: nil},
+		{Cookie: "session=abc123; theme=dark; other=1", Expected: nil},
+		{Cookie: "session=xyz", Expected: ErrPattern},
+		{Cookie: "session=abc123; theme=blue", Expected: ErrEnumNotFound},
+	}
+
+	for i, test := range tests {
+		header := http.Header{}
+		if test.Cookie != "" {
+			header.Set("Cookie", test.Cookie)
+		}
+		err := r.ValidateInputs(Validate{Cookie: spec}, &Inputs{Header: header})
+		if !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", i, test.Expected, err)
+		}
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) && validationErr.In != InCookie {
+			t.Errorf("%v: expected the error in %v, got %v", i, InCookie, validationErr.In)
+		}
+	}
+
+	err := r.ValidateInputs(Validate{Cookie: spec.Unknown(false)}, &Inputs{Header: http.Header{"Cookie": []string{"session=a; other=1"}}})
+	if !errors.Is(err, ErrUnknown) {
+		t.Errorf("expected %v got %v", ErrUnknown, err)
+	}
+}
+
+// benchmarkBody is a body with a few hundred values, like the larger ones seen in practice.
+func benchmarkBody() (Field, []byte) {
+	field := Object(map[string]Field{
+		"name": String().Required(),
+		"items": Array().Items(Object(map[string]Field{
+			"id":    Integer().Required().Min(1),
+			"sku":   String().Pattern("^[A-Z0-9-]+$"),
+			"price": Number().Min(0),
+			"tags":  Array().Items(String()),
+		})),
+	})
+	var items []string
+	for i := 1; i <= 100; i++ {
+		items = append(items, fmt.Sprintf(`{"id":%v,"sku":"SKU-%v","price":%v.5,"tags":["a","b"]}`, i, i, i))
+	}
+	return field, []byte(`{"name":"order","items":[` + strings.Join(items, ",") + `]}`)
+}
+
+func BenchmarkValidate(b *testing.B) {
+	field, data := benchmarkBody()
+	router := NewRouter("", "", &TestAdapter{})
+	val := Validate{Body: field}
+	val.compiled = compile(val, router.settings)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var body interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			b.Fatal(err)
+		}
+		if err := router.ValidateInputs(val, &Inputs{Body: body}); err != nil {
+			b.Fatal(err)
+		}
 	}
 }
@@ -0,0 +1,164 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jakecoffman/crud/option"
+	"net/http"
+)
+
+// ErrorRenderer writes the response when a request fails decoding or validation, a typed handler
+// returns an error, or a response fails validation. Set it with ErrorRendererOption on NewRouter,
+// or on a single Spec with Spec.Options.
+type ErrorRenderer interface {
+	// RenderError writes the response for err, which is usually a ValidationError or ValidationErrors.
+	// Errors returned by typed handlers are passed as a StatusError with the status to respond with.
+	RenderError(w http.ResponseWriter, r *http.Request, err error)
+	// ErrorSchema describes the response body so it can be documented in the Swagger.
+	ErrorSchema() JsonSchema
+}
+
+// CollectedErrorRenderer is an ErrorRenderer rendering the errors collected with option.CollectErrors
+// differently than a single error. Its CollectedErrorSchema documents the 400 responses of the specs
+// collecting errors.
+type CollectedErrorRenderer interface {
+	ErrorRenderer
+	// CollectedErrorSchema describes the response body when errors are being collected.
+	CollectedErrorSchema() JsonSchema
+}
+
+// ErrorRendererOption configures the ErrorRenderer used by the router or a Spec. Defaults to ProblemRenderer.
+func ErrorRendererOption(renderer ErrorRenderer) option.Option {
+	return option.Option{ErrorRenderer: renderer}
+}
+
+// RenderError writes err to the response using the ErrorRenderer configured for the spec.
+// Adapters call this when decoding or validating a request fails.
+func (r *Router) RenderError(w http.ResponseWriter, req *http.Request, spec *Spec, err error) {
+	r.settings.apply(spec.Options...).errorRenderer.RenderError(w, req, err)
+}
+
+// Problem is an RFC 7807 problem details object with the validation errors as an extension member.
+type Problem struct {
+	Type   string           `json:"type"`
+	Title  string           `json:"title"`
+	Status int              `json:"status"`
+	Detail string           `json:"detail,omitempty"`
+	Errors ValidationErrors `json:"errors,omitempty"`
+}
+
+// ProblemRenderer renders errors as application/problem+json, see https://www.rfc-editor.org/rfc/rfc7807.
+type ProblemRenderer struct{}
+
+func (ProblemRenderer) RenderError(w http.ResponseWriter, r *http.Request, err error) {
+	status := statusOf(err)
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: err.Error(),
+	}
+
+	var errs ValidationErrors
+	var validationErr *ValidationError
+	if errors.As(err, &errs) {
+		problem.Detail = fmt.Sprintf("request has %v validation errors", len(errs))
+		problem.Errors = errs
+	} else if errors.As(err, &validationErr) {
+		problem.Errors = ValidationErrors{validationErr}
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+func (ProblemRenderer) ErrorSchema() JsonSchema {
+	return JsonSchema{
+		Type: KindObject,
+		Properties: map[string]JsonSchema{
+			"type":   {Type: KindString, Example: "about:blank"},
+			"title":  {Type: KindString, Example: "Bad Request"},
+			"status": {Type: KindInteger, Example: http.StatusBadRequest},
+			"detail": {Type: KindString},
+			"errors": {
+				Type:  KindArray,
+				Items: &validationErrorSchema,
+			},
+		},
+		Required: []string{"type", "title", "status"},
+	}
+}
+
+// JSONErrorRenderer renders the error message as a JSON string, or the list of errors when
+// errors are being collected.
+type JSONErrorRenderer struct{}
+
+func (JSONErrorRenderer) RenderError(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusOf(err))
+
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		_ = json.NewEncoder(w).Encode(errs)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(err.Error())
+}
+
+func (JSONErrorRenderer) ErrorSchema() JsonSchema {
+	return JsonSchema{
+		Type:        KindString,
+		Description: "The error message",
+	}
+}
+
+func (JSONErrorRenderer) CollectedErrorSchema() JsonSchema {
+	return JsonSchema{
+		Type:        KindArray,
+		Items:       &validationErrorSchema,
+		Description: "The validation errors, or the error message when the request can't be decoded",
+	}
+}
+
+// errorSchema returns the schema of the validation errors the renderer writes with the settings,
+// a list of them when errors are being collected and the renderer tells them apart.
+func (s settings) errorSchema() JsonSchema {
+	if renderer, ok := s.errorRenderer.(CollectedErrorRenderer); ok && s.collectErrors > 1 {
+		return renderer.CollectedErrorSchema()
+	}
+	return s.errorRenderer.ErrorSchema()
+}
+
+// statusOf returns the status code of the response for err: the status of a StatusError, 413 Request
+// Entity Too Large for bodies over option.MaxBodyBytes, 500 Internal Server Error for responses failing
+// validation, otherwise 400 Bad Request.
+func statusOf(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status
+	}
+	if errors.Is(err, ErrBodyTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) && validationErr.In == InResponse {
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+// validationErrorSchema describes a ValidationError encoded as JSON.
+var validationErrorSchema = JsonSchema{
+	Type: KindObject,
+	Properties: map[string]JsonSchema{
+		"in":      {Type: KindString, Enum: []interface{}{InPath, InQuery, InHeader, InCookie, InBody, InForm, InResponse}},
+		"pointer": {Type: KindString, Example: "/items/0/name"},
+		"rule":    {Type: KindString, Example: "required"},
+		"limit":   {Description: "The constraint that was violated, like the maximum"},
+		"value":   {Description: "The rejected value"},
+		"message": {Type: KindString},
+	},
+	Required: []string{"in", "pointer", "rule", "message"},
+}
@@ -0,0 +1,138 @@
+package crud
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jakecoffman/crud/option"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestProblemRenderer(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := &ValidationError{In: InQuery, Pointer: "/limit", Rule: "maximum", Limit: 25., Value: "30", Err: ErrMaximum}
+	ProblemRenderer{}.RenderError(w, httptest.NewRequest("GET", "/", nil), err)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("unexpected content type %v", w.Header().Get("Content-Type"))
+	}
+	var problem map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem["status"] != 400. || problem["detail"] != "query validation failed for field limit: maximum exceeded" {
+		t.Errorf("unexpected problem %v", w.Body.String())
+	}
+	errs := problem["errors"].([]interface{})
+	if len(errs) != 1 || errs[0].(map[string]interface{})["pointer"] != "/limit" {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
+type teapotRenderer struct{}
+
+func (teapotRenderer) RenderError(w http.ResponseWriter, r *http.Request, err error) {
+	w.WriteHeader(http.StatusTeapot)
+	_, _ = fmt.Fprint(w, err)
+}
+
+func (teapotRenderer) ErrorSchema() JsonSchema {
+	return JsonSchema{Type: KindString, Description: "teapot"}
+}
+
+func TestErrorRendererOption(t *testing.T) {
+	adapter := NewServeMuxAdapter()
+	router := NewRouter("title", "1.0", adapter, ErrorRendererOption(teapotRenderer{}))
+	err := router.Add(Spec{
+		Method:  "GET",
+		Path:    "/widgets",
+		Handler: func(w http.ResponseWriter, r *http.Request) {},
+		Validate: Validate{
+			Query: Object(map[string]Field{
+				"limit": Integer().Required(),
+			}),
+		},
+	}, Spec{
+		Method:  "GET",
+		Path:    "/widgets/{id}",
+		Handler: func(w http.ResponseWriter, r *http.Request) {},
+		Validate: Validate{
+			Path: Object(map[string]Field{
+				"id": Integer(),
+			}),
+		},
+		Options: []option.Option{ErrorRendererOption(JSONErrorRenderer{})},
+	}, Spec{
+		Method:  "GET",
+		Path:    "/health",
+		Handler: func(w http.ResponseWriter, r *http.Request) {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", "/widgets", nil))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("expected status code %d, got %d", http.StatusTeapot, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", "/widgets/a", nil))
+	if w.Code != http.StatusBadRequest || w.Body.String() != "\"path validation failed for field id: wrong type passed\"\n" {
+		t.Errorf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+
+	if router.Swagger.Paths["/widgets"].Get.Responses["400"].Schema.Description != "teapot" {
+		t.Errorf("expected the 400 response to be documented by the renderer")
+	}
+	if router.Swagger.Paths["/widgets/{id}"].Get.Responses["400"].Schema.Type != KindString {
+		t.Errorf("expected the 400 response to be documented by the spec's renderer")
+	}
+	if _, ok := router.Swagger.Paths["/health"].Get.Responses["400"]; ok {
+		t.Errorf("expected no 400 response without validation")
+	}
+	if _, ok := defaultResponse["400"]; ok {
+		t.Errorf("the default responses must not be modified")
+	}
+}
+
+func TestJSONErrorRenderer_Collected(t *testing.T) {
+	adapter := NewServeMuxAdapter()
+	router := NewRouter("title", "1.0", adapter, ErrorRendererOption(JSONErrorRenderer{}), option.CollectErrors(5))
+
+	err := router.Add(Spec{
+		Method:  "GET",
+		Path:    "/widgets",
+		Handler: func(w http.ResponseWriter, r *http.Request) {},
+		Validate: Validate{
+			Query: Object(map[string]Field{
+				"limit": Integer().Required(),
+				"page":  Integer().Required(),
+			}),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", "/widgets", nil))
+	var errs []map[string]interface{}
+	if err = json.Unmarshal(w.Body.Bytes(), &errs); err != nil || len(errs) != 2 {
+		t.Errorf("expected a list of errors, got %v %v", w.Code, w.Body.String())
+	}
+
+	schema := router.Swagger.Paths["/widgets"].Get.Responses["400"].Schema
+	if schema.Type != KindArray || schema.Items == nil {
+		t.Fatalf("expected the 400 response to be documented as a list, got %#v", schema)
+	}
+	if in := schema.Items.Properties["in"].Enum; !slices.Contains(in, interface{}(InCookie)) || !slices.Contains(in, interface{}(InResponse)) {
+		t.Errorf("expected every location to be documented, got %v", in)
+	}
+}
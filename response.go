@@ -0,0 +1,349 @@
+package crud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ResponseValidator returns middleware that buffers the response of the handler and checks it against
+// the responses documented in the spec, see option.ValidateResponses. Validated responses are buffered
+// in full and only sent once the handler returns, so they can't be streamed. It returns nil when response
+// validation is off for the spec, so adapters can skip installing it. Errors returned by typed handlers,
+// see Handle, are passed through unchecked since their status codes can't be documented up front.
+func (r *Router) ResponseValidator(spec *Spec) func(http.Handler) http.Handler {
+	s := r.settings.apply(spec.Options...)
+	if s.responseSampleRate <= 0 {
+		return nil
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if s.responseSampleRate < 1 && rand.Float64() >= s.responseSampleRate {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			var rendered bool
+			req = req.WithContext(context.WithValue(req.Context(), renderedKey{}, &rendered))
+			buffer := &responseBuffer{ResponseWriter: w, header: http.Header{}, status: http.StatusOK}
+			next.ServeHTTP(buffer, req)
+
+			// errors of typed handlers are rendered by the ErrorRenderer with any status they choose
+			if rendered && buffer.status >= 400 {
+				buffer.flush()
+				return
+			}
+			if err := r.CheckResponse(spec, buffer.status, buffer.Header(), buffer.body.Bytes()); err != nil {
+				if s.onResponseError == nil {
+					// the headers of the handler are dropped with its response
+					s.errorRenderer.RenderError(w, req, err)
+					return
+				}
+				s.onResponseError(req, err)
+			}
+			buffer.flush()
+		})
+	}
+}
+
+// renderedKey is the context key of the flag telling the ResponseValidator that the ErrorRenderer
+// wrote the response for an error of a typed handler.
+type renderedKey struct{}
+
+// markRendered sets the flag of renderedKey, if the response of r is being validated.
+func markRendered(r *http.Request) {
+	if rendered, ok := r.Context().Value(renderedKey{}).(*bool); ok {
+		*rendered = true
+	}
+}
+
+// CheckResponse validates a response against the responses documented in the spec. The status code
+// must be documented, and JSON bodies must match the schema of that status code.
+func (r *Router) CheckResponse(spec *Spec, status int, header http.Header, body []byte) error {
+	var responses map[string]Response
+	if spec.Validate.compiled != nil {
+		responses = spec.Validate.compiled.responses
+	} else {
+		// a spec that wasn't added to the router
+		responses = r.responses(spec)
+	}
+	response, ok := responses[strconv.Itoa(status)]
+	if !ok {
+		response, ok = responses["default"]
+	}
+	if !ok {
+		codes := make([]string, 0, len(responses))
+		for code := range responses {
+			codes = append(codes, code)
+		}
+		slices.Sort(codes)
+		return &ValidationError{In: InResponse, Rule: "status", Limit: codes, Value: status, Err: ErrUndocumented}
+	}
+
+	// the default response is only a placeholder, there is nothing to check against
+	defs := r.Swagger.Definitions
+	if spec.Responses == nil || !response.Schema.constrained(defs) || len(body) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+
+	// with UseNumber like request bodies, so integers past 2^53 aren't rounded
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{In: InResponse, Rule: "type", Limit: mediaType, Err: fmt.Errorf("invalid JSON: %w", err)}
+	}
+	c := &collector{}
+	if err := validateSchema(nil, &response.Schema, value, defs, c); err != nil {
+		return err
+	}
+	return c.err()
+}
+
+// validateSchema is like validateObject but checks a value against the JsonSchema documenting it.
+// References are resolved against defs, the definitions of the Swagger.
+func validateSchema(loc *location, schema *JsonSchema, value interface{}, defs map[string]JsonSchema, c *collector) error {
+	fail := func(rule string, limit interface{}, err error) error {
+		return c.add(&ValidationError{In: InResponse, Pointer: loc.pointer(), Rule: rule, Limit: limit, Value: value, Err: err})
+	}
+
+	schema, ok := resolveRef(schema, defs)
+	if !ok {
+		// like an external reference there is nothing to check against
+		return nil
+	}
+
+	if len(schema.Enum) > 0 && !slices.ContainsFunc(schema.Enum, func(e interface{}) bool { return jsonEqual(e, value) }) {
+		return fail("enum", schema.Enum, ErrEnumNotFound)
+	}
+
+	for i := range schema.AllOf {
+		if err := validateSchema(loc, &schema.AllOf[i], value, defs, c); err != nil {
+			return err
+		}
+	}
+	matching := func(schemas []JsonSchema) (n int) {
+		for i := range schemas {
+			if validateSchema(loc, &schemas[i], value, defs, &collector{}) == nil {
+				n++
+			}
+		}
+		return n
+	}
+	if len(schema.AnyOf) > 0 && matching(schema.AnyOf) == 0 {
+		return fail("anyOf", nil, ErrAnyOf)
+	}
+	if len(schema.OneOf) > 0 && matching(schema.OneOf) != 1 {
+		return fail("oneOf", nil, ErrOneOf)
+	}
+	if schema.Not != nil && matching([]JsonSchema{*schema.Not}) == 1 {
+		return fail("not", nil, ErrNot)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		if schema.Type != "" && !schema.XNullable {
+			return fail("type", schema.Type, ErrWrongType)
+		}
+	case bool:
+		if schema.Type != "" && schema.Type != KindBoolean {
+			return fail("type", schema.Type, ErrWrongType)
+		}
+	case string:
+		if schema.Type != "" && schema.Type != KindString {
+			return fail("type", schema.Type, ErrWrongType)
+		}
+		if schema.MaxLength != nil && utf8.RuneCountInString(v) > *schema.MaxLength {
+			return fail("maxLength", *schema.MaxLength, ErrMaximum)
+		}
+		if schema.MinLength != nil && utf8.RuneCountInString(v) < *schema.MinLength {
+			return fail("minLength", *schema.MinLength, ErrMinimum)
+		}
+		if schema.Pattern != "" && !compilePattern(schema.Pattern).MatchString(v) {
+			return fail("pattern", schema.Pattern, ErrPattern)
+		}
+	case json.Number:
+		// checked like numbers of request bodies, by a field with the limits of the schema
+		field := Field{
+			kind:         KindNumber,
+			format:       schema.Format,
+			min:          schema.Minimum,
+			max:          schema.Maximum,
+			exclusiveMin: schema.ExclusiveMinimum,
+			exclusiveMax: schema.ExclusiveMaximum,
+		}
+		switch schema.Type {
+		case "", KindNumber:
+		case KindInteger:
+			field.kind = KindInteger
+		default:
+			return fail("type", schema.Type, ErrWrongType)
+		}
+		if schema.MultipleOf != 0 {
+			field.multipleOf = &schema.MultipleOf
+		}
+		if err := field.validateJSONNumber(v); err != nil {
+			return c.add(newValidationError(InResponse, loc.pointer(), &field, v, err))
+		}
+	case []interface{}:
+		if schema.Type != "" && schema.Type != KindArray {
+			return fail("type", schema.Type, ErrWrongType)
+		}
+		if schema.MaxItems != nil && len(v) > *schema.MaxItems {
+			return fail("maxItems", *schema.MaxItems, ErrMaximum)
+		}
+		if schema.MinItems != nil && len(v) < *schema.MinItems {
+			return fail("minItems", *schema.MinItems, ErrMinimum)
+		}
+		if schema.UniqueItems && !uniqueItems(v) {
+			return fail("uniqueItems", nil, ErrUniqueItems)
+		}
+		if schema.Items != nil {
+			for i, item := range v {
+				if err := validateSchema(loc.item(i), schema.Items, item, defs, c); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		if schema.Type != "" && schema.Type != KindObject {
+			return fail("type", schema.Type, ErrWrongType)
+		}
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				err := &ValidationError{In: InResponse, Pointer: loc.property(name).pointer(), Rule: "required", Err: ErrRequired}
+				if err := c.add(err); err != nil {
+					return err
+				}
+			}
+		}
+		if schema.MinProperties != nil && len(v) < *schema.MinProperties {
+			return fail("minProperties", *schema.MinProperties, ErrMinimum)
+		}
+		if schema.MaxProperties != nil && len(v) > *schema.MaxProperties {
+			return fail("maxProperties", *schema.MaxProperties, ErrMaximum)
+		}
+		for _, name := range sortedKeys(v) {
+			child, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties == nil {
+					continue
+				}
+				child = *schema.AdditionalProperties
+			}
+			if err := validateSchema(loc.property(name), &child, v[name], defs, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// constrained returns true if the schema says anything about the value it documents. A reference
+// is as constrained as the definition it refers to.
+func (s JsonSchema) constrained(defs map[string]JsonSchema) bool {
+	schema, ok := resolveRef(&s, defs)
+	if !ok {
+		return false
+	}
+	return schema.Type != "" || len(schema.OneOf) > 0 || len(schema.AnyOf) > 0 || len(schema.AllOf) > 0 || schema.Not != nil
+}
+
+// resolveRef follows the reference of the schema to its definition in defs, returning false if it
+// isn't one of them. A schema without a reference is returned as is.
+func resolveRef(schema *JsonSchema, defs map[string]JsonSchema) (*JsonSchema, bool) {
+	// definitions referring to each other in a loop never resolve
+	for range len(defs) + 1 {
+		if schema.Ref == "" {
+			return schema, true
+		}
+		def, ok := defs[strings.TrimPrefix(schema.Ref, "#/definitions/")]
+		if !ok || !strings.HasPrefix(schema.Ref, "#/definitions/") {
+			return schema, false
+		}
+		schema = &def
+	}
+	return schema, false
+}
+
+// jsonEqual compares decoded JSON with values from Go code, where numbers may not be float64.
+// Numbers are compared by value, so json.Number("1.0") equals 1.
+func jsonEqual(expected, actual interface{}) bool {
+	if e, ok := numberRat(expected); ok {
+		a, ok := numberRat(actual)
+		return ok && e.Cmp(a) == 0
+	}
+	return expected == actual
+}
+
+var patterns sync.Map
+
+// compilePattern caches the compiled patterns of JsonSchema, which are only kept as strings.
+func compilePattern(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		// an invalid pattern documented in a response shouldn't fail every response
+		re = regexp.MustCompile("")
+	}
+	patterns.Store(pattern, re)
+	return re
+}
+
+// responseBuffer holds on to a response so it can be checked before it is sent.
+type responseBuffer struct {
+	http.ResponseWriter
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status = status
+		b.wroteHeader = true
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController. Flushing it sends the headers
+// before the buffered response is checked, so handlers that stream shouldn't be validated.
+func (b *responseBuffer) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+// flush sends the buffered response.
+func (b *responseBuffer) flush() {
+	header := b.ResponseWriter.Header()
+	for key, values := range b.header {
+		header[key] = values
+	}
+	b.ResponseWriter.WriteHeader(b.status)
+	_, _ = b.ResponseWriter.Write(b.body.Bytes())
+}
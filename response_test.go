@@ -0,0 +1,170 @@
+package crud
+
+import (
+	"errors"
+	"github.com/jakecoffman/crud/option"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var widgetResponses = map[string]Response{
+	"200": {
+		Schema: JsonSchema{
+			Type: KindObject,
+			Properties: map[string]JsonSchema{
+				"id":   {Type: KindInteger},
+				"name": {Type: KindString, Pattern: "^[a-z]+$"},
+				"tags": {Type: KindArray, Items: &JsonSchema{Type: KindString}},
+				"kind": {Type: KindString, Enum: []interface{}{"a", "b"}},
+			},
+			Required: []string{"id"},
+		},
+		Description: "OK",
+	},
+	"204": {Description: "No Content"},
+}
+
+func TestCheckResponse(t *testing.T) {
+	r := NewRouter("", "", &TestAdapter{})
+	spec := &Spec{Responses: widgetResponses}
+	header := http.Header{"Content-Type": []string{"application/json"}}
+
+	tests := []struct {
+		Status   int
+		Body     string
+		Pointer  string
+		Expected error
+	}{
+		{Status: 200, Body: `{"id":1,"name":"bob","tags":["a"],"kind":"a"}`},
+		{Status: 204},
+		{Status: 500, Expected: ErrUndocumented},
+		{Status: 200, Body: `{"name":"bob"}`, Pointer: "/id", Expected: ErrRequired},
+		{Status: 200, Body: `{"id":1.5}`, Pointer: "/id", Expected: ErrWrongType},
+		{Status: 200, Body: `{"id":1,"name":"Bob"}`, Pointer: "/name", Expected: ErrPattern},
+		{Status: 200, Body: `{"id":1,"tags":["a",2]}`, Pointer: "/tags/1", Expected: ErrWrongType},
+		{Status: 200, Body: `{"id":1,"kind":"c"}`, Pointer: "/kind", Expected: ErrEnumNotFound},
+		{Status: 200, Body: `[]`, Expected: ErrWrongType},
+	}
+
+	for i, test := range tests {
+		err := r.CheckResponse(spec, test.Status, header, []byte(test.Body))
+		if !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", i, test.Expected, err)
+			continue
+		}
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) && validationErr.Pointer != test.Pointer {
+			t.Errorf("%v: expected pointer %v got %v", i, test.Pointer, validationErr.Pointer)
+		}
+	}
+
+	// bounds of zero, references and integers past 2^53 are checked too
+	zero := 0.0
+	max := float64(1 << 53)
+	r.Swagger.Definitions["Count"] = JsonSchema{Type: KindObject, Properties: map[string]JsonSchema{
+		"n":   {Type: KindInteger, Minimum: &zero},
+		"big": {Type: KindInteger, Maximum: &max},
+	}}
+	counted := &Spec{Responses: map[string]Response{"200": {Schema: JsonSchema{Ref: "#/definitions/Count"}}}}
+	for body, expected := range map[string]error{
+		`{"n":0,"big":9007199254740992}`: nil,
+		`{"n":-5}`:                       ErrMinimum,
+		`{"big":9007199254740993}`:       ErrMaximum,
+	} {
+		if err := r.CheckResponse(counted, 200, header, []byte(body)); !errors.Is(err, expected) {
+			t.Errorf("%v: expected %v got %v", body, expected, err)
+		}
+	}
+
+	// only JSON is checked
+	if err := r.CheckResponse(spec, 200, http.Header{}, []byte("hello")); err != nil {
+		t.Error(err)
+	}
+	// the default response documents nothing so anything goes
+	if err := r.CheckResponse(&Spec{}, 201, header, []byte(`{}`)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResponseValidator(t *testing.T) {
+	newRouter := func(body string, options ...option.Option) *ServeMuxAdapter {
+		adapter := NewServeMuxAdapter()
+		router := NewRouter("title", "1.0", adapter, options...)
+		err := router.Add(Spec{
+			Method: "GET",
+			Path:   "/widgets",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-Widget", "1")
+				_, _ = io.WriteString(w, body)
+			},
+			Responses: widgetResponses,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return adapter
+	}
+
+	t.Run("passes valid responses through", func(t *testing.T) {
+		adapter := newRouter(`{"id":1}`, option.ValidateResponses(1))
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", "/widgets", nil))
+		if w.Code != http.StatusOK || w.Body.String() != `{"id":1}` {
+			t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("fails invalid responses", func(t *testing.T) {
+		adapter := newRouter(`{"name":"bob"}`, option.ValidateResponses(1))
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", "/widgets", nil))
+		if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("unexpected response %v %v %v", w.Code, w.Header(), w.Body.String())
+		}
+	})
+
+	t.Run("unwraps to the writer of the adapter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		buffer := &responseBuffer{ResponseWriter: w, header: http.Header{}, status: http.StatusOK}
+		if err := http.NewResponseController(buffer).Flush(); err != nil || !w.Flushed {
+			t.Errorf("expected the recorder to be flushed, got %v", err)
+		}
+	})
+
+	t.Run("renders invalid responses with the error renderer", func(t *testing.T) {
+		adapter := newRouter(`{"name":"bob"}`, option.ValidateResponses(1), ErrorRendererOption(JSONErrorRenderer{}))
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", "/widgets", nil))
+		if w.Code != http.StatusInternalServerError || w.Header().Get("X-Widget") != "" || !strings.HasPrefix(w.Body.String(), `"response validation failed`) {
+			t.Errorf("unexpected response %v %v %v", w.Code, w.Header(), w.Body.String())
+		}
+	})
+
+	t.Run("reports invalid responses to the hook", func(t *testing.T) {
+		var reported error
+		adapter := newRouter(`{"name":"bob"}`, option.ValidateResponses(1), option.OnResponseError(func(r *http.Request, err error) {
+			reported = err
+		}))
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", "/widgets", nil))
+		if w.Code != http.StatusOK || w.Body.String() != `{"name":"bob"}` {
+			t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+		}
+		if !errors.Is(reported, ErrRequired) {
+			t.Errorf("expected the hook to get the error, got %v", reported)
+		}
+	})
+
+	t.Run("off by default", func(t *testing.T) {
+		adapter := newRouter(`{"name":"bob"}`)
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, httptest.NewRequest("GET", "/widgets", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("unexpected response %v %v", w.Code, w.Body.String())
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"fmt"
 	"github.com/jakecoffman/crud/option"
+	"net/http"
 	"regexp"
 	"strings"
 )
@@ -12,16 +13,68 @@ import (
 type Router struct {
 	// Swagger is exposed so the user can edit additional optional fields.
 	Swagger Swagger
+	// OpenAPI is the same API as an OpenAPI 3.1 document, e.g. set the Servers here.
+	OpenAPI OpenAPI
 
 	// The underlying router being used behind Adapter interface.
 	adapter Adapter
 
-	// used for automatically incrementing the model name, e.g. Model 1, Model 2.
-	modelCounter int
-
 	// options
-	stripUnknown bool
-	allowUnknown bool
+	settings
+}
+
+// settings are the options that can be set on the router and overridden by each Spec.
+type settings struct {
+	stripUnknown  bool
+	allowUnknown  bool
+	collectErrors int
+	errorRenderer ErrorRenderer
+
+	responseSampleRate float64
+	onResponseError    func(r *http.Request, err error)
+
+	limits     limits
+	strictJSON bool
+}
+
+// apply returns a copy of the settings with the options applied.
+func (s settings) apply(options ...option.Option) settings {
+	for _, o := range options {
+		if o.StripUnknown != nil {
+			s.stripUnknown = *o.StripUnknown
+		}
+		if o.AllowUnknown != nil {
+			s.allowUnknown = *o.AllowUnknown
+		}
+		if o.CollectErrors != nil {
+			s.collectErrors = *o.CollectErrors
+		}
+		if renderer, ok := o.ErrorRenderer.(ErrorRenderer); ok {
+			s.errorRenderer = renderer
+		}
+		if o.ResponseSampleRate != nil {
+			s.responseSampleRate = *o.ResponseSampleRate
+		}
+		if o.OnResponseError != nil {
+			s.onResponseError = o.OnResponseError
+		}
+		if o.MaxBodyBytes != nil {
+			s.limits.bytes = *o.MaxBodyBytes
+		}
+		if o.MaxDepth != nil {
+			s.limits.depth = *o.MaxDepth
+		}
+		if o.MaxElements != nil {
+			s.limits.elements = *o.MaxElements
+		}
+		if o.MaxStringLength != nil {
+			s.limits.stringLength = *o.MaxStringLength
+		}
+		if o.StrictJSON != nil {
+			s.strictJSON = *o.StrictJSON
+		}
+	}
+	return s
 }
 
 type Adapter interface {
@@ -38,18 +91,20 @@ func NewRouter(title, version string, adapter Adapter, options ...option.Option)
 			Paths:       map[string]*Path{},
 			Definitions: map[string]JsonSchema{},
 		},
-		adapter:      adapter,
-		modelCounter: 1,
-		stripUnknown: true,
-		allowUnknown: true,
-	}
-	for _, o := range options {
-		if o.StripUnknown != nil {
-			r.stripUnknown = *o.StripUnknown
-		} else if o.AllowUnknown != nil {
-			r.allowUnknown = *o.AllowUnknown
-		}
+		OpenAPI: OpenAPI{
+			OpenAPI:    "3.1.0",
+			Info:       Info{Title: title, Version: version},
+			Paths:      map[string]*PathItem{},
+			Components: Components{Schemas: map[string]*Schema{}},
+		},
+		adapter: adapter,
+		settings: settings{
+			stripUnknown:  true,
+			allowUnknown:  true,
+			errorRenderer: ProblemRenderer{},
+		},
 	}
+	r.settings = r.settings.apply(options...)
 	return r
 }
 
@@ -59,6 +114,10 @@ func (r *Router) Add(specs ...Spec) error {
 	for i := range specs {
 		spec := specs[i]
 
+		if h, ok := spec.Handler.(describer); ok {
+			spec.Handler = h.describe(r, &spec)
+		}
+
 		if err := spec.Valid(); err != nil {
 			return err
 		}
@@ -108,10 +167,7 @@ func (r *Router) Add(specs ...Spec) error {
 		default:
 			panic("Unhandled method " + spec.Method)
 		}
-		operation.Responses = defaultResponse
-		if spec.Responses != nil {
-			operation.Responses = spec.Responses
-		}
+		operation.Responses = r.responses(&spec)
 		operation.Tags = spec.Tags
 		operation.Description = spec.Description
 		operation.Summary = spec.Summary
@@ -131,18 +187,32 @@ func (r *Router) Add(specs ...Spec) error {
 		if spec.Validate.FormData.Initialized() {
 			params := spec.Validate.FormData.ToSwaggerParameters("formData")
 			operation.Parameters = append(operation.Parameters, params...)
+			operation.Consumes = []string{"application/x-www-form-urlencoded"}
+			for _, param := range params {
+				if param.Type == KindFile {
+					operation.Consumes = []string{"multipart/form-data"}
+				}
+			}
 		}
 		if spec.Validate.Body.Initialized() {
-			modelName := fmt.Sprintf("Model-%v", r.modelCounter)
+			defs := &definitions{schemas: r.Swagger.Definitions}
+			schema, err := defs.body(&spec.Validate.Body)
+			if err != nil {
+				return err
+			}
 			parameter := Parameter{
-				In:     "body",
-				Name:   "body",
-				Schema: &Ref{fmt.Sprint("#/definitions/", modelName)},
+				In:          "body",
+				Name:        "body",
+				Schema:      &Ref{schema.Ref},
+				Description: r.settings.apply(spec.Options...).describeBody(),
 			}
-			r.Swagger.Definitions[modelName] = spec.Validate.Body.ToJsonSchema()
-			r.modelCounter++
 			operation.Parameters = append(operation.Parameters, parameter)
 		}
+		r.addOpenAPI(&spec, operation)
+
+		// resolved once so requests don't work it out again, or race doing so
+		spec.Validate.compiled = compile(spec.Validate, r.settings.apply(spec.Options...))
+		spec.Validate.compiled.responses = operation.Responses
 
 		if err := r.adapter.Install(r, &spec); err != nil {
 			return err
@@ -159,10 +229,56 @@ type Validate struct {
 	Path     Field
 	FormData Field
 	Header   Field
+	// Cookie is validated on every request. Unknown cookies are allowed unless the field says
+	// otherwise with Unknown(false), they are never stripped, and defaults are checked but not
+	// written to the request. It is only documented in the OpenAPI 3.1 document because Swagger
+	// 2.0 has no cookie parameters.
+	Cookie Field
+
+	// compiled is set by Router.Add, see compile
+	compiled *compiled
 }
 
-// Serve installs the swagger and the swagger-ui and runs the server.
+// responses returns the responses documented for the spec, including the 400 response
+// rendered by the ErrorRenderer when the spec has validation, and the 413 response when
+// the size of its JSON body is limited.
+func (r *Router) responses(spec *Spec) map[string]Response {
+	s := r.settings.apply(spec.Options...)
+	responses := map[string]Response{}
+	documented := defaultResponse
+	if spec.Responses != nil {
+		documented = spec.Responses
+	}
+	for code, response := range documented {
+		responses[code] = response
+	}
+	if _, ok := responses["400"]; !ok && spec.Validate.initialized() {
+		responses["400"] = Response{
+			Schema:      s.errorSchema(),
+			Description: "Invalid request",
+		}
+	}
+	body := spec.Validate.Body
+	if _, ok := responses["413"]; !ok && s.limits.bytes > 0 && body.Initialized() && body.kind != KindFile {
+		responses["413"] = Response{
+			Schema:      s.errorRenderer.ErrorSchema(),
+			Description: fmt.Sprintf("Request body larger than %v bytes", s.limits.bytes),
+		}
+	}
+	return responses
+}
+
+// initialized returns true if any of the inputs will be validated.
+func (v Validate) initialized() bool {
+	return v.Query.Initialized() || v.Body.Initialized() || v.Path.Initialized() ||
+		v.FormData.Initialized() || v.Header.Initialized() || v.Cookie.Initialized()
+}
+
+// Serve installs the swagger, the OpenAPI document and the swagger-ui and runs the server.
 func (r *Router) Serve(addr string) error {
+	if err := r.adapter.Install(r, r.openAPISpec()); err != nil {
+		return err
+	}
 	return r.adapter.Serve(&r.Swagger, addr)
 }
 
@@ -0,0 +1,175 @@
+package crud
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// condition is the value a property must have for RequiredIf to apply.
+type condition struct {
+	property string
+	value    interface{}
+}
+
+// DependentRequired requires the dependents whenever the property is present, e.g. a phone
+// number once a shipping method is chosen. It panics if the field isn't an object.
+func (f Field) DependentRequired(property string, dependents ...string) Field {
+	if f.kind != KindObject {
+		panic("DependentRequired is only for objects")
+	}
+	if len(dependents) == 0 {
+		panic("DependentRequired needs at least one dependent")
+	}
+	dependentRequired := maps.Clone(f.dependentRequired)
+	if dependentRequired == nil {
+		dependentRequired = map[string][]string{}
+	}
+	dependentRequired[property] = append(slices.Clip(dependentRequired[property]), dependents...)
+	f.dependentRequired = dependentRequired
+	return f
+}
+
+// RequiredIf makes a property of an object required when its sibling property has the value,
+// e.g. String().RequiredIf("shipping", "express") for a phone number. The object checks it
+// once its properties are validated, so defaults of the sibling count.
+func (f Field) RequiredIf(property string, value interface{}) Field {
+	f.requiredIf = &condition{property: property, value: value}
+	return f
+}
+
+// OneRequired requires exactly one of the properties to be present, e.g. either an email or a
+// user ID. It panics if the field isn't an object.
+func (f Field) OneRequired(properties ...string) Field {
+	if f.kind != KindObject {
+		panic("OneRequired is only for objects")
+	}
+	if len(properties) < 2 {
+		panic("OneRequired needs at least two properties")
+	}
+	f.oneRequired = append(slices.Clip(f.oneRequired), properties)
+	return f
+}
+
+// MutuallyExclusive allows at most one of the properties to be present. It panics if the field
+// isn't an object.
+func (f Field) MutuallyExclusive(properties ...string) Field {
+	if f.kind != KindObject {
+		panic("MutuallyExclusive is only for objects")
+	}
+	if len(properties) < 2 {
+		panic("MutuallyExclusive needs at least two properties")
+	}
+	f.exclusive = append(slices.Clip(f.exclusive), properties)
+	return f
+}
+
+// Refine adds a check of the object as a whole, for rules the others can't express. It is Custom
+// for objects: it runs once the properties and the other rules passed, and its errors are
+// wrapped with ErrCustom. Numbers in bodies are json.Number. It panics if the field isn't an object.
+func (f Field) Refine(check func(map[string]interface{}) error) Field {
+	if f.kind != KindObject {
+		panic("Refine is only for objects")
+	}
+	if check == nil {
+		panic("Refine needs a check")
+	}
+	return f.Custom(func(value interface{}) error {
+		return check(value.(map[string]interface{}))
+	})
+}
+
+// validateObjectRules checks the rules about the properties of an object, after the properties were validated.
+func validateObjectRules(loc *location, field *Field, v map[string]interface{}, c *collector) error {
+	// an explicit null doesn't count, it says there is no value like a missing property does
+	present := func(property string) bool {
+		return v[property] != nil
+	}
+
+	for _, property := range sortedKeys(field.dependentRequired) {
+		if !present(property) {
+			continue
+		}
+		for _, dependent := range field.dependentRequired[property] {
+			if !present(dependent) {
+				err := &ValidationError{In: InBody, Pointer: loc.property(dependent).pointer(), Rule: "dependentRequired", Limit: property, Err: ErrRequired}
+				if err := c.add(err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, name := range field.properties() {
+		when := field.obj[name].requiredIf
+		if when == nil || present(name) || !present(when.property) || !jsonEqual(when.value, v[when.property]) {
+			continue
+		}
+		limit := map[string]interface{}{when.property: when.value}
+		err := &ValidationError{In: InBody, Pointer: loc.property(name).pointer(), Rule: "requiredIf", Limit: limit, Err: ErrRequired}
+		if err := c.add(err); err != nil {
+			return err
+		}
+	}
+
+	for _, properties := range field.oneRequired {
+		if count(properties, present) != 1 {
+			err := &ValidationError{In: InBody, Pointer: loc.pointer(), Rule: "oneRequired", Limit: properties, Err: ErrOneRequired}
+			if err := c.add(err); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, properties := range field.exclusive {
+		if count(properties, present) > 1 {
+			err := &ValidationError{In: InBody, Pointer: loc.pointer(), Rule: "mutuallyExclusive", Limit: properties, Err: ErrMutuallyExclusive}
+			if err := c.add(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// count returns how many of the properties are present.
+func count(properties []string, present func(string) bool) (n int) {
+	for _, property := range properties {
+		if present(property) {
+			n++
+		}
+	}
+	return n
+}
+
+// describe returns the description of the field, followed by the rules that the schema can't express.
+func (f *Field) describe() string {
+	var sentences []string
+	if f.description != "" {
+		sentences = append(sentences, f.description)
+	}
+	if f.requiredIf != nil {
+		value, _ := json.Marshal(f.requiredIf.value)
+		sentences = append(sentences, fmt.Sprintf("Required when %v is %s.", f.requiredIf.property, value))
+	}
+	for _, property := range sortedKeys(f.dependentRequired) {
+		sentences = append(sentences, fmt.Sprintf("When %v is present, %v required.", property, listOf(f.dependentRequired[property], "is", "are")))
+	}
+	for _, properties := range f.oneRequired {
+		sentences = append(sentences, fmt.Sprintf("Exactly one of %v is required.", strings.Join(properties, ", ")))
+	}
+	for _, properties := range f.exclusive {
+		sentences = append(sentences, fmt.Sprintf("At most one of %v can be present.", strings.Join(properties, ", ")))
+	}
+	return strings.Join(sentences, " ")
+}
+
+// listOf joins the names, followed by the verb agreeing with how many there are.
+func listOf(names []string, singular, plural string) string {
+	if len(names) == 1 {
+		return names[0] + " " + singular
+	}
+	return strings.Join(names, ", ") + " " + plural
+}
@@ -0,0 +1,111 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jakecoffman/crud/option"
+)
+
+func TestObjectRules(t *testing.T) {
+	order := Object(map[string]Field{
+		"shipping": String().Enum("standard", "express").Default("standard"),
+		"phone":    String().Nullable().RequiredIf("shipping", "express"),
+		"gift":     Boolean(),
+		"message":  String().Nullable(),
+		"email":    String(),
+		"userId":   Integer().Nullable(),
+		"coupon":   String(),
+		"voucher":  String().Nullable(),
+	}).
+		DependentRequired("gift", "message").
+		OneRequired("email", "userId").
+		MutuallyExclusive("coupon", "voucher").
+		Refine(func(v map[string]interface{}) error {
+			if v["coupon"] == "EXPIRED" {
+				return errors.New("coupon expired")
+			}
+			return nil
+		})
+
+	tests := []struct {
+		Input    string
+		Pointer  string
+		Rule     string
+		Expected error
+	}{
+		{Input: `{"email":"a@example.com"}`},
+		{Input: `{"userId":1,"shipping":"express","phone":"555"}`},
+		{Input: `{"userId":1,"shipping":"express"}`, Pointer: "/phone", Rule: "requiredIf", Expected: ErrRequired},
+		{Input: `{"userId":1,"gift":true}`, Pointer: "/message", Rule: "dependentRequired", Expected: ErrRequired},
+		{Input: `{"userId":1,"gift":true,"message":"hi"}`},
+		{Input: `{}`, Pointer: "", Rule: "oneRequired", Expected: ErrOneRequired},
+		{Input: `{"email":"a@example.com","userId":1}`, Pointer: "", Rule: "oneRequired", Expected: ErrOneRequired},
+		{Input: `{"email":"a@example.com","coupon":"A","voucher":"B"}`, Pointer: "", Rule: "mutuallyExclusive", Expected: ErrMutuallyExclusive},
+		{Input: `{"email":"a@example.com","coupon":"EXPIRED"}`, Pointer: "", Rule: "custom", Expected: ErrCustom},
+		// null counts as missing
+		{Input: `{"userId":1,"shipping":"express","phone":null}`, Pointer: "/phone", Rule: "requiredIf", Expected: ErrRequired},
+		{Input: `{"userId":1,"gift":true,"message":null}`, Pointer: "/message", Rule: "dependentRequired", Expected: ErrRequired},
+		{Input: `{"email":"a@example.com","userId":null}`},
+		{Input: `{"email":"a@example.com","coupon":"A","voucher":null}`},
+		// the rules run after the properties are validated
+		{Input: `{"userId":1,"shipping":"overnight"}`, Pointer: "/shipping", Rule: "enum", Expected: ErrEnumNotFound},
+	}
+
+	for i, test := range tests {
+		var input interface{}
+		_ = json.Unmarshal([]byte(test.Input), &input)
+		err := order.Validate(input)
+		if !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", i, test.Expected, err)
+			continue
+		}
+		var validationErr *ValidationError
+		if test.Expected != nil && errors.As(err, &validationErr) && (validationErr.Pointer != test.Pointer || validationErr.Rule != test.Rule) {
+			t.Errorf("%v: expected %q at %q, got %q at %q", i, test.Rule, test.Pointer, validationErr.Rule, validationErr.Pointer)
+		}
+	}
+
+	r := NewRouter("", "", &TestAdapter{}, option.CollectErrors(10))
+	var input interface{}
+	_ = json.Unmarshal([]byte(`{"gift":true,"coupon":"A","voucher":"B"}`), &input)
+	var errs ValidationErrors
+	if err := r.Validate(Validate{Body: order}, nil, input, nil); !errors.As(err, &errs) || len(errs) != 3 {
+		t.Errorf("expected all rules to be reported, got %v", err)
+	}
+
+	schema := order.ToJsonSchema()
+	for _, sentence := range []string{"When gift is present, message is required.", "Exactly one of email, userId is required.", "At most one of coupon, voucher can be present."} {
+		if !strings.Contains(schema.Description, sentence) {
+			t.Errorf("expected the description to say %q, got %q", sentence, schema.Description)
+		}
+	}
+	if description := schema.Properties["phone"].Description; description != `Required when shipping is "express".` {
+		t.Errorf("unexpected description of phone %q", description)
+	}
+	if dependentRequired := order.toSchema(nil).DependentRequired; !reflect.DeepEqual(dependentRequired, map[string][]string{"gift": {"message"}}) {
+		t.Errorf("unexpected dependentRequired %v", dependentRequired)
+	}
+}
+
+func TestObjectRules_Panic(t *testing.T) {
+	for _, build := range []func(){
+		func() { String().DependentRequired("a", "b") },
+		func() { Object(nil).DependentRequired("a") },
+		func() { Array().OneRequired("a", "b") },
+		func() { Object(nil).MutuallyExclusive("a") },
+		func() { Integer().Refine(func(map[string]interface{}) error { return nil }) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic")
+				}
+			}()
+			build()
+		}()
+	}
+}
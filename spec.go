@@ -2,6 +2,7 @@ package crud
 
 import (
 	"fmt"
+	"github.com/jakecoffman/crud/option"
 	"strings"
 )
 
@@ -25,6 +26,8 @@ type Spec struct {
 	Validate Validate
 	// Responses specifies the responses in Swagger. If none provided a default is used.
 	Responses map[string]Response
+	// Options override the router's options for this route only, e.g. option.CollectErrors(10)
+	Options []option.Option
 }
 
 var methods = map[string]struct{}{
@@ -73,5 +76,50 @@ func (s Spec) Valid() error {
 		}
 	}
 
+	if s.Validate.Cookie.Initialized() && s.Validate.Cookie.kind != KindObject {
+		return fmt.Errorf("cookie must be an object")
+	}
+
+	if err := checkValueDefaults(InQuery, s.Validate.Query); err != nil {
+		return err
+	}
+	if err := checkValueDefaults(InHeader, s.Validate.Header); err != nil {
+		return err
+	}
+	if err := checkValueDefaults(InCookie, s.Validate.Cookie); err != nil {
+		return err
+	}
+
+	if s.Validate.FormData.Initialized() {
+		if s.Validate.FormData.kind != KindObject {
+			return fmt.Errorf("formData must be an object")
+		}
+		if s.Validate.Body.Initialized() {
+			return fmt.Errorf("body and formData cannot be used together")
+		}
+	}
+
+	return nil
+}
+
+// checkValueDefaults rejects defaults of query, header and cookie fields that can't be sent as their
+// values: objects, and arrays of anything but strings, numbers and booleans.
+func checkValueDefaults(in string, values Field) error {
+	for _, name := range sortedKeys(values.obj) {
+		items, ok := values.obj[name]._default.([]interface{})
+		if !ok {
+			items = []interface{}{values.obj[name]._default}
+		}
+		for _, item := range items {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				return fmt.Errorf("%v default of '%v' must be a string, number or boolean, or an array of them", in, name)
+			case nil:
+				if ok {
+					return fmt.Errorf("%v default of '%v' can't have null items", in, name)
+				}
+			}
+		}
+	}
 	return nil
 }
@@ -23,6 +23,28 @@ func TestSpec_Valid(t *testing.T) {
 				"id": Number(),
 			})},
 		},
+		{
+			Method: "POST",
+			Path:   "/5",
+			Validate: Validate{
+				Body:     Object(map[string]Field{}),
+				FormData: Object(map[string]Field{"file": File()}),
+			},
+		},
+		{
+			Method: "GET",
+			Path:   "/6",
+			Validate: Validate{Query: Object(map[string]Field{
+				"filter": Object(map[string]Field{"a": String()}).Default(map[string]interface{}{"a": "b"}),
+			})},
+		},
+		{
+			Method: "GET",
+			Path:   "/7",
+			Validate: Validate{Header: Object(map[string]Field{
+				"x-filters": Array().Default([]interface{}{map[string]interface{}{"a": "b"}}),
+			})},
+		},
 	}
 
 	for _, spec := range specs {
@@ -0,0 +1,379 @@
+package crud
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// FromStruct builds a Field from the Go type of v, usually a struct or a pointer to one.
+// A reflect.Type can be passed instead of a value.
+//
+// Properties are named like encoding/json names them. Fields are required unless they are
+// pointers or tagged omitempty, the zero value "" is allowed for strings that are only
+// required this way. Pointers are nullable too. Constraints are read from the crud tag:
+//
+//	Name string   `json:"name" crud:"required,min=1,max=25,pattern=^[a-z]+$"`
+//	Kind string   `json:"kind" crud:"enum=small|large,default=small"`
+//	Due  string   `json:"due" crud:"format=date-time"`
+//	Tags []string `json:"tags" crud:"max=5,dive,min=1"`
+//
+// The rules after dive are for the items of an array. The common rules of go-playground/validator
+// tags are translated as well: required, omitempty, min, max, len, gt, gte, lt, lte, oneof, dive and
+// formats like email or uuid. Strings tagged omitempty allow "" like the validator does. Each tag has
+// a dive of its own. FromStruct panics if a tag is invalid or has a rule it can't translate, like ne.
+func FromStruct(v any) Field {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	if t == nil {
+		panic("FromStruct needs a typed value")
+	}
+	return fieldOf(t)
+}
+
+// fieldOf builds a Field describing how values of the Go type t are encoded as JSON.
+func fieldOf(t reflect.Type) Field {
+	return typeField(t, map[reflect.Type]bool{})
+}
+
+// typeField does the work of fieldOf. Seen holds the structs being described to stop recursive types.
+func typeField(t reflect.Type, seen map[reflect.Type]bool) Field {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeField(t.Elem(), seen)
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int64:
+		return Int64()
+	case reflect.Uint, reflect.Uint64:
+		return Uint64()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Integer()
+	case reflect.Float32, reflect.Float64:
+		return Number()
+	case reflect.String:
+		return String()
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json encodes []byte as base64
+			return Byte()
+		}
+		return Array().Items(typeField(t.Elem(), seen))
+	case reflect.Map:
+		values := typeField(t.Elem(), seen)
+		if !values.Initialized() {
+			// arbitrary values, so don't strip them
+			return Object(map[string]Field{}).Strip(false)
+		}
+		f := Map(values)
+		switch t.Key().Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = f.KeyPattern(`^-?[0-9]+$`)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = f.KeyPattern(`^[0-9]+$`)
+		}
+		return f
+	case reflect.Struct:
+		if t == timeType {
+			return DateTime()
+		}
+		if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+			return String()
+		}
+		if seen[t] {
+			// a recursive type, the nested values are left as they are
+			return Object(map[string]Field{}).Strip(false)
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		obj, complete := structFields(t, seen)
+		f := Object(obj)
+		if !complete {
+			// some fields can't be described, keep them around for the handler
+			f = f.Strip(false)
+		}
+		return f
+	}
+	// interfaces, channels, functions etc. can't be described
+	return Field{}
+}
+
+// structFields returns the JSON fields of the struct t, and false if any of them couldn't be described.
+func structFields(t reflect.Type, seen map[reflect.Type]bool) (obj map[string]Field, complete bool) {
+	obj = map[string]Field{}
+	complete = true
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || isPromoted(sf) {
+			continue
+		}
+		name, ok := jsonName(sf)
+		if !ok {
+			continue
+		}
+		field := structField(sf, seen, true)
+		if !field.Initialized() {
+			complete = false
+			continue
+		}
+		obj[name] = field
+	}
+	return
+}
+
+// isPromoted returns true for embedded structs encoding/json flattens into the outer object.
+// Their fields are listed separately by reflect.VisibleFields.
+func isPromoted(sf reflect.StructField) bool {
+	if !sf.Anonymous {
+		return false
+	}
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" {
+		return false
+	}
+	t := sf.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// jsonName returns the name encoding/json uses for the struct field, and false if it is skipped.
+func jsonName(sf reflect.StructField) (string, bool) {
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = sf.Name
+	}
+	return name, true
+}
+
+// structField builds the Field of a struct field, applying the rules in its tags. When implicit is
+// true a field that isn't a pointer or omitempty is required.
+func structField(sf reflect.StructField, seen map[reflect.Type]bool, implicit bool) Field {
+	field := typeField(sf.Type, seen)
+	if !field.Initialized() {
+		return field
+	}
+
+	// each tag is split at its own dive, so the rules of one tag don't end up on the items of the other
+	rules, items := splitDive(validateRules(sf))
+	tagged, taggedItems := splitDive(crudRules(sf.Tag.Get("crud")))
+	rules, items = append(rules, tagged...), append(items, taggedItems...)
+	if len(items) > 0 && field.kind == KindArray && field.arr != nil {
+		field = field.Items(applyRules(*field.arr, items, sf))
+	}
+	var required, optional bool
+	for _, rule := range rules {
+		switch rule.key {
+		case "required":
+			required = true
+		case "optional":
+			optional = true
+		case "omitempty":
+			optional = true
+		}
+	}
+	field = applyRules(field, rules, sf)
+
+	if sf.Type.Kind() == reflect.Pointer {
+		// nil pointers are encoded as null
+		field.nullable = true
+		optional = true
+	}
+	_, options, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if slices.Contains(strings.Split(options, ","), "omitempty") {
+		optional = true
+	}
+	if required {
+		return field.Required()
+	}
+	if implicit && !optional && field._default == nil {
+		field = field.Required()
+		if field.kind == KindString {
+			field = field.Allow("")
+		}
+	}
+	return field
+}
+
+// tagRule is a single rule of a crud tag, e.g. min=1.
+type tagRule struct {
+	key, value string
+}
+
+// splitDive splits the rules of a tag into those of the field and those after dive, which are for its items.
+func splitDive(rules []tagRule) (field, items []tagRule) {
+	i := slices.IndexFunc(rules, func(rule tagRule) bool { return rule.key == "dive" })
+	if i == -1 {
+		return rules, nil
+	}
+	return rules[:i], rules[i+1:]
+}
+
+var crudTagKeys = map[string]bool{
+	"required": true, "min": true, "max": true, "pattern": true, "enum": true, "format": true, "default": true, "dive": true,
+}
+
+// crudRules splits a crud tag into its rules.
+func crudRules(tag string) (rules []tagRule) {
+	if tag == "" {
+		return nil
+	}
+	for _, part := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		if !crudTagKeys[key] && len(rules) > 0 && rules[len(rules)-1].key == "pattern" {
+			// patterns can contain commas, e.g. a{1,3}
+			rules[len(rules)-1].value += "," + part
+			continue
+		}
+		rules = append(rules, tagRule{key: key, value: value})
+	}
+	return rules
+}
+
+// validateFormats maps go-playground/validator tags to the formats they check.
+var validateFormats = map[string]string{
+	"email":    "email",
+	"uuid":     "uuid",
+	"uuid4":    "uuid",
+	"url":      "uri",
+	"uri":      "uri",
+	"ipv4":     "ipv4",
+	"ipv6":     "ipv6",
+	"hostname": "hostname",
+	"base64":   "byte",
+}
+
+// validateRules translates the go-playground/validator tag of the struct field into crud tag rules.
+// It panics on rules that have no equivalent.
+func validateRules(sf reflect.StructField) (rules []tagRule) {
+	tag := sf.Tag.Get("validate")
+	if tag == "" || tag == "-" {
+		return nil
+	}
+	unknown := func(part string) {
+		panic(fmt.Sprintf("unknown rule %q in validate tag of %v", part, sf.Name))
+	}
+	for _, part := range strings.Split(tag, ",") {
+		if strings.Contains(part, "|") {
+			// alternatives can't be expressed
+			unknown(part)
+		}
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "required", "dive", "omitempty":
+			rules = append(rules, tagRule{key: key})
+		case "min", "gte":
+			rules = append(rules, tagRule{key: "min", value: value})
+		case "max", "lte":
+			rules = append(rules, tagRule{key: "max", value: value})
+		case "gt":
+			rules = append(rules, tagRule{key: "exclusiveMin", value: value})
+		case "lt":
+			rules = append(rules, tagRule{key: "exclusiveMax", value: value})
+		case "len":
+			rules = append(rules, tagRule{key: "min", value: value}, tagRule{key: "max", value: value})
+		case "oneof":
+			rules = append(rules, tagRule{key: "enum", value: strings.Join(strings.Fields(value), "|")})
+		case "datetime":
+			switch value {
+			case time.RFC3339:
+				rules = append(rules, tagRule{key: "format", value: FormatDateTime})
+			case fullDate:
+				rules = append(rules, tagRule{key: "format", value: FormatDate})
+			default:
+				unknown(part)
+			}
+		default:
+			format, ok := validateFormats[key]
+			if !ok {
+				unknown(part)
+			}
+			rules = append(rules, tagRule{key: "format", value: format})
+		}
+	}
+	return rules
+}
+
+// applyRules sets the constraints of the rules on the field. The struct field is used to report bad rules.
+func applyRules(field Field, rules []tagRule, sf reflect.StructField) Field {
+	number := func(rule tagRule) float64 {
+		n, err := strconv.ParseFloat(rule.value, 64)
+		if err != nil {
+			panic(fmt.Sprintf("invalid %v in tag of %v: %v", rule.key, sf.Name, err))
+		}
+		return n
+	}
+	value := func(rule tagRule, s string) interface{} {
+		v, err := convert(s, field)
+		if err != nil || v == nil {
+			panic(fmt.Sprintf("invalid %v %q in tag of %v", rule.key, s, sf.Name))
+		}
+		return v
+	}
+
+	for _, rule := range rules {
+		switch rule.key {
+		case "required", "optional":
+			// handled by structField
+		case "omitempty":
+			// handled by structField too, and the validator skips the rules of empty values
+			if field.kind == KindString {
+				field = field.Allow("")
+			}
+		case "min":
+			field = field.Min(number(rule))
+		case "max":
+			field = field.Max(number(rule))
+		case "exclusiveMin":
+			if field.kind == KindNumber || field.kind == KindInteger {
+				field = field.ExclusiveMin(number(rule))
+			} else {
+				// lengths are whole numbers
+				field = field.Min(math.Floor(number(rule)) + 1)
+			}
+		case "exclusiveMax":
+			if field.kind == KindNumber || field.kind == KindInteger {
+				field = field.ExclusiveMax(number(rule))
+			} else {
+				field = field.Max(math.Ceil(number(rule)) - 1)
+			}
+		case "pattern":
+			field = field.Pattern(rule.value)
+		case "enum":
+			var values []interface{}
+			for _, s := range strings.Split(rule.value, "|") {
+				values = append(values, value(rule, s))
+			}
+			field = field.Enum(values...)
+		case "format":
+			switch rule.value {
+			case "date-time", FormatDateTime:
+				field = field.Format(FormatDateTime)
+			default:
+				field = field.Format(rule.value)
+			}
+		case "default":
+			field = field.Default(value(rule, rule.value))
+		default:
+			panic(fmt.Sprintf("unknown rule %q in crud tag of %v", rule.key, sf.Name))
+		}
+	}
+	return field
+}
@@ -0,0 +1,171 @@
+package crud
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Audit struct {
+	Created time.Time  `json:"created"`
+	Deleted *time.Time `json:"deleted"`
+}
+
+type part struct {
+	Serial string `json:"serial" crud:"pattern=^[A-Z]{2,4}-\\d+$"`
+}
+
+type gadget struct {
+	Audit
+	Name     string            `json:"name" crud:"required,min=1,max=25"`
+	Size     string            `json:"size" crud:"enum=small|large,default=small"`
+	Count    int               `json:"count,omitempty" crud:"min=0,max=10"`
+	Owner    *string           `json:"owner"`
+	Email    string            `json:"email" validate:"required,email"`
+	Color    string            `json:"color" validate:"omitempty,oneof=red green"`
+	Tags     []string          `json:"tags" validate:"max=3,dive,min=2"`
+	Codes    []string          `json:"codes,omitempty" validate:"dive,min=1" crud:"max=10"`
+	Parts    []part            `json:"parts,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Children []gadget          `json:"children,omitempty"`
+	Data     []byte            `json:"data,omitempty"`
+	Skip     string            `json:"-"`
+	private  string
+}
+
+func TestFromStruct(t *testing.T) {
+	f := FromStruct(&gadget{})
+
+	if f.kind != KindObject {
+		t.Fatal("expected an object", f.kind)
+	}
+	var names []string
+	for _, name := range sortedKeys(f.obj) {
+		names = append(names, name)
+	}
+	expected := []string{"children", "codes", "color", "count", "created", "data", "deleted", "email", "labels", "name", "owner", "parts", "size", "tags"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v got %v", expected, names)
+	}
+
+	isRequired := func(name string) bool {
+		required := f.obj[name].required
+		return required != nil && *required
+	}
+	for name, required := range map[string]bool{
+		"name": true, "email": true, "created": true, "tags": true,
+		"size": false, "count": false, "owner": false, "deleted": false, "color": false,
+	} {
+		if isRequired(name) != required {
+			t.Errorf("expected %v required to be %v", name, required)
+		}
+	}
+
+	if name := f.obj["name"]; *name.min != 1 || *name.max != 25 || name.allow.has("") {
+		t.Errorf("unexpected name %#v", name)
+	}
+	if size := f.obj["size"]; !reflect.DeepEqual(size.enum, enum{"small", "large"}) || size._default != "small" {
+		t.Errorf("unexpected size %#v", size)
+	}
+	if f.obj["email"].format != "email" || !reflect.DeepEqual(f.obj["color"].enum, enum{"red", "green"}) {
+		t.Errorf("expected validate tags to be translated")
+	}
+	if tags := f.obj["tags"]; *tags.max != 3 || tags.arr.kind != KindString || *tags.arr.min != 2 {
+		t.Errorf("expected rules after dive to apply to the items")
+	}
+	if codes := f.obj["codes"]; *codes.max != 10 || *codes.arr.min != 1 || codes.arr.max != nil {
+		t.Errorf("expected the crud tag to apply to the array, not the items after the dive of the validate tag")
+	}
+	if f.obj["created"].format != FormatDateTime || f.obj["data"].format != "byte" {
+		t.Errorf("unexpected formats")
+	}
+	if parts := f.obj["parts"]; parts.arr.kind != KindObject || parts.arr.obj["serial"].pattern.String() != `^[A-Z]{2,4}-\d+$` {
+		t.Errorf("unexpected parts %#v", parts.arr)
+	}
+	if children := f.obj["children"]; children.arr.kind != KindObject || len(children.arr.obj) != 0 {
+		t.Errorf("expected recursion to stop")
+	}
+
+	valid := map[string]interface{}{
+		"name":    "gizmo",
+		"email":   "a@example.com",
+		"created": "2024-01-02T03:04:05Z",
+		"tags":    []interface{}{"ab"},
+	}
+	if err := f.Validate(valid); err != nil {
+		t.Error(err)
+	}
+	valid["tags"] = []interface{}{"a"}
+	if err := f.Validate(valid); !errors.Is(err, ErrMinimum) {
+		t.Errorf("expected %v got %v", ErrMinimum, err)
+	}
+}
+
+func TestFromStruct_Tags(t *testing.T) {
+	type bad struct {
+		Count int `crud:"minimum=1"`
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected unknown rules to panic")
+			}
+		}()
+		FromStruct(bad{})
+	}()
+
+	for _, tag := range []string{"ne=0", "required_if=Count 1", "min=1|max=2", "datetime=2006"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected validate tag %q to panic", tag)
+				}
+			}()
+			FromStruct(reflect.StructOf([]reflect.StructField{{Name: "Count", Type: reflect.TypeOf(0), Tag: reflect.StructTag(`validate:"` + tag + `"`)}}))
+		}()
+	}
+
+	type bounds struct {
+		Email  string   `json:"email" validate:"omitempty,email"`
+		Score  int      `json:"score" validate:"gt=0,lt=10"`
+		Code   string   `json:"code" validate:"gt=2,lt=5"`
+		Emails []string `json:"emails" validate:"dive,omitempty,email"`
+	}
+	b := FromStruct(bounds{})
+	if score := b.obj["score"]; !score.exclusiveMin || !score.exclusiveMax {
+		t.Errorf("expected gt and lt to be exclusive, got %#v", score)
+	}
+	for i, test := range []struct {
+		Input    map[string]interface{}
+		Expected error
+	}{
+		{Input: map[string]interface{}{"email": "", "score": 5, "code": "abc", "emails": []interface{}{""}}},
+		{Input: map[string]interface{}{"email": "a@example.com", "score": 9, "code": "abcd", "emails": []interface{}{"a@example.com"}}},
+		{Input: map[string]interface{}{"email": "nope", "score": 5, "code": "abc", "emails": []interface{}{}}, Expected: ErrFormat},
+		{Input: map[string]interface{}{"email": "", "score": 0, "code": "abc", "emails": []interface{}{}}, Expected: ErrMinimum},
+		{Input: map[string]interface{}{"email": "", "score": 10, "code": "abc", "emails": []interface{}{}}, Expected: ErrMaximum},
+		{Input: map[string]interface{}{"email": "", "score": 5, "code": "ab", "emails": []interface{}{}}, Expected: ErrMinimum},
+		{Input: map[string]interface{}{"email": "", "score": 5, "code": "abcde", "emails": []interface{}{}}, Expected: ErrMaximum},
+		{Input: map[string]interface{}{"email": "", "score": 5, "code": "abc", "emails": []interface{}{"nope"}}, Expected: ErrFormat},
+	} {
+		if err := b.Validate(test.Input); !errors.Is(err, test.Expected) {
+			t.Errorf("%v: expected %v got %v", i, test.Expected, err)
+		}
+	}
+
+	type counts struct {
+		Count  int     `crud:"enum=1|2|3"`
+		Ratio  float64 `crud:"default=0.5"`
+		Nested struct {
+			On bool `json:"on"`
+		} `json:"nested"`
+	}
+	f := FromStruct(reflect.TypeOf(counts{}))
+	if !reflect.DeepEqual(f.obj["Count"].enum, enum{1, 2, 3}) || f.obj["Ratio"]._default != 0.5 {
+		t.Errorf("expected tag values to be converted to the field kind")
+	}
+	if f.obj["nested"].obj["on"].kind != KindBoolean {
+		t.Errorf("unexpected nested %#v", f.obj["nested"])
+	}
+}
@@ -15,6 +15,7 @@ type Info struct {
 }
 
 type JsonSchema struct {
+	Ref         string                `json:"$ref,omitempty"`
 	Type        string                `json:"type,omitempty"`
 	Format      string                `json:"format,omitempty"`
 	Properties  map[string]JsonSchema `json:"properties,omitempty"`
@@ -22,11 +23,39 @@ type JsonSchema struct {
 	Required    []string              `json:"required,omitempty"`
 	Example     interface{}           `json:"example,omitempty"`
 	Description string                `json:"description,omitempty"`
-	Minimum     float64               `json:"minimum,omitempty"`
-	Maximum     float64               `json:"maximum,omitempty"`
+	Minimum     *float64              `json:"minimum,omitempty"`
+	Maximum     *float64              `json:"maximum,omitempty"`
+	MultipleOf  float64               `json:"multipleOf,omitempty"`
 	Enum        []interface{}         `json:"enum,omitempty"`
 	Default     interface{}           `json:"default,omitempty"`
 	Pattern     string                `json:"pattern,omitempty"`
+	AllOf       []JsonSchema          `json:"allOf,omitempty"`
+
+	// Swagger 2.0 has no oneOf, anyOf or not, so they are extensions here and only the OpenAPI 3.1
+	// document has them
+	OneOf []JsonSchema `json:"x-oneOf,omitempty"`
+	AnyOf []JsonSchema `json:"x-anyOf,omitempty"`
+	Not   *JsonSchema  `json:"x-not,omitempty"`
+
+	// Discriminator is the property telling the definitions extending this one apart, which have
+	// the value in XDiscriminatorValue, see Discriminated
+	Discriminator       string `json:"discriminator,omitempty"`
+	XDiscriminatorValue string `json:"x-discriminator-value,omitempty"`
+	XNullable           bool   `json:"x-nullable,omitempty"`
+
+	// in Swagger 2.0 these make Minimum and Maximum exclusive
+	ExclusiveMinimum bool `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum bool `json:"exclusiveMaximum,omitempty"`
+	UniqueItems      bool `json:"uniqueItems,omitempty"`
+
+	AdditionalProperties *JsonSchema `json:"additionalProperties,omitempty"`
+	MinProperties        *int        `json:"minProperties,omitempty"`
+	MaxProperties        *int        `json:"maxProperties,omitempty"`
+
+	MinLength *int `json:"minLength,omitempty"`
+	MaxLength *int `json:"maxLength,omitempty"`
+	MinItems  *int `json:"minItems,omitempty"`
+	MaxItems  *int `json:"maxItems,omitempty"`
 }
 
 type Path struct {
@@ -40,6 +69,7 @@ type Path struct {
 
 type Operation struct {
 	Tags        []string            `json:"tags,omitempty"`
+	Consumes    []string            `json:"consumes,omitempty"`
 	Parameters  []Parameter         `json:"parameters,omitempty"`
 	Responses   map[string]Response `json:"responses"`
 	Description string              `json:"description"`
@@ -58,6 +88,14 @@ type Parameter struct {
 	Description      string        `json:"description,omitempty"`
 	Minimum          *float64      `json:"minimum,omitempty"`
 	Maximum          *float64      `json:"maximum,omitempty"`
+	ExclusiveMinimum bool          `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum bool          `json:"exclusiveMaximum,omitempty"`
+	MultipleOf       *float64      `json:"multipleOf,omitempty"`
+	MinLength        *int          `json:"minLength,omitempty"`
+	MaxLength        *int          `json:"maxLength,omitempty"`
+	MinItems         *int          `json:"minItems,omitempty"`
+	MaxItems         *int          `json:"maxItems,omitempty"`
+	UniqueItems      bool          `json:"uniqueItems,omitempty"`
 	Enum             []interface{} `json:"enum,omitempty"`
 	Pattern          string        `json:"pattern,omitempty"`
 	Default          interface{}   `json:"default,omitempty"`